@@ -9,12 +9,32 @@ import (
 type Config struct {
 	Store Store
 
-	// Secret is the salt for hash session id before put to store
+	// Secret is the salt for hash session id before put to store,
+	// Secret is ignored when Secrets is not empty
 	Secret []byte
 
-	// Keys is the keys to sign session id
+	// Secrets is the salts for hash session id before put to store,
+	// the first secret uses to hash session id,
+	// all secrets use to find session from store for rotate secret.
+	// Session found by old secret will be moved to the first secret when save
+	Secrets [][]byte
+
+	// Keys is the keys to sign session id using HMAC-SHA1,
+	// when Signer is set, Keys only use to verify old cookies
 	Keys [][]byte
 
+	// Signer signs session id, signed cookie contains algorithm tag
+	// to verify by the same algorithm
+	Signer Signer
+
+	// Verifiers are the signers to verify cookies that signed by old algorithm
+	Verifiers []Signer
+
+	// Transport carries session id between client and server,
+	// if Transport is nil, session id will be carried in cookie.
+	// Transport is ignored when Store is CookieStore
+	Transport Transport
+
 	// Cookie config
 	Domain   string
 	HTTPOnly bool
@@ -27,10 +47,36 @@ type Config struct {
 	// if IdleTimeout is zero, it will use MaxAge
 	IdleTimeout time.Duration
 
+	// AbsoluteTimeout is the maximum lifetime of session since created,
+	// session will be treated as new session when exceeded
+	// even if session was active (Rolling or Resave).
+	// Zero means no absolute timeout
+	AbsoluteTimeout time.Duration
+
 	// DeleteOldSession deletes the old session from store when regenerate,
 	// better not to delete old session to avoid user loss session when unstable network
 	DeleteOldSession bool
 
+	// OptimisticLock enables optimistic concurrency control,
+	// Save returns ErrConflict when session was modified by other request after get.
+	// Store must implement VersionedStore, wrapper stores forward to wrapped store,
+	// then Save returns ErrNotSupported if wrapped store does not implement VersionedStore
+	OptimisticLock bool
+
+	// Merge resolves conflict when OptimisticLock enabled,
+	// Merge receives session data in store and session data to save,
+	// then returns merged data to save. Current data is nil if session was deleted.
+	//
+	// If Merge is nil, Save returns ErrConflict
+	Merge func(current, data Data) Data
+
+	// PartialUpdate saves only changed keys instead of the whole session data,
+	// concurrent requests that modify different keys will not overwrite each other.
+	// Store must implement PatchStore, PartialUpdate is ignored when OptimisticLock enabled.
+	// Wrapper stores forward to wrapped store, then Save returns ErrNotSupported
+	// if wrapped store does not implement PatchStore
+	PartialUpdate bool
+
 	// Resave forces session to save to store even if session was not modified
 	Resave bool
 
@@ -48,6 +94,13 @@ type Config struct {
 
 	// GenerateID is session id generator
 	GenerateID func() string
+
+	// ErrorHandler handles error from middleware when get or save session,
+	// if ErrorHandler is nil, middleware will panic when save session failed.
+	//
+	// Session is nil when get session failed.
+	// If ErrorHandler writes response, the rest of handler's response will be discarded
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, s *Session, err error)
 }
 
 // Secure config
@@ -0,0 +1,76 @@
+package session
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CookieStore is the store that keeps session data in client's cookies.
+//
+// When Config.Store implements CookieStore, manager will encode the whole session data
+// into cookies instead of session id, and split into chunks when it too large.
+//
+// Store methods will be called the same as server-side store,
+// CookieStore may implement them as no-op.
+type CookieStore interface {
+	Store
+
+	// EncodeCookie encodes session data into cookie value
+	EncodeCookie(name string, value Data, opt StoreOption) (string, error)
+
+	// DecodeCookie decodes cookie value into session data,
+	// must return ErrNotFound if value is invalid or expired
+	DecodeCookie(name string, value string) (Data, error)
+}
+
+// cookieChunkSize is the maximum length of each cookie value,
+// browsers limit each cookie around 4096 bytes including name and attributes
+const cookieChunkSize = 3800
+
+func chunkCookieName(name string, i int) string {
+	if i == 0 {
+		return name
+	}
+	return name + "_" + strconv.Itoa(i)
+}
+
+// readCookieChunks reads and joins chunked cookie value,
+// returns joined value and number of chunks
+func readCookieChunks(r *http.Request, name string) (string, int) {
+	var (
+		b strings.Builder
+		n int
+	)
+	for {
+		c, err := r.Cookie(chunkCookieName(name, n))
+		if err != nil {
+			break
+		}
+		b.WriteString(c.Value)
+		n++
+	}
+	return b.String(), n
+}
+
+// splitCookieChunks splits value into chunks
+func splitCookieChunks(value string) []string {
+	var r []string
+	for len(value) > cookieChunkSize {
+		r = append(r, value[:cookieChunkSize])
+		value = value[cookieChunkSize:]
+	}
+	return append(r, value)
+}
+
+// removeCookieChunks removes session's cookie chunks start from index
+func removeCookieChunks(w http.ResponseWriter, s *Session, from int) {
+	for i := from; i < s.chunks; i++ {
+		c := makeCookie(s, chunkCookieName(s.Name, i), "")
+		c.MaxAge = -1
+		c.Expires = time.Time{}
+		http.SetCookie(w, c)
+	}
+	s.chunks = from
+}
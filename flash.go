@@ -3,12 +3,16 @@ package session
 import (
 	"bytes"
 	"encoding/gob"
+	"sync"
 )
 
 type flashData map[string][]interface{}
 
 // Flash type
+//
+// Flash is safe for concurrent use by multiple goroutines
 type Flash struct {
+	mu      sync.Mutex
 	v       flashData
 	changed bool
 }
@@ -23,6 +27,9 @@ func (f *Flash) decode(b []byte) error {
 
 // Encode encodes flash
 func (f *Flash) encode() ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	// empty flash encode to empty bytes
 	if len(f.v) == 0 {
 		return []byte{}, nil
@@ -38,7 +45,10 @@ func (f *Flash) encode() ([]byte, error) {
 
 // Values returns slice of given key
 func (f *Flash) Values(key string) []interface{} {
-	if !f.Has(key) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.has(key) {
 		return []interface{}{}
 	}
 
@@ -50,6 +60,9 @@ func (f *Flash) Values(key string) []interface{} {
 
 // Set sets value to flash
 func (f *Flash) Set(key string, value interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	if !f.changed {
 		f.changed = true
 	}
@@ -61,6 +74,9 @@ func (f *Flash) Set(key string, value interface{}) {
 
 // Add adds value to flash
 func (f *Flash) Add(key string, value interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
 	if !f.changed {
 		f.changed = true
 	}
@@ -72,7 +88,10 @@ func (f *Flash) Add(key string, value interface{}) {
 
 // Get gets value from flash
 func (f *Flash) Get(key string) interface{} {
-	if !f.Has(key) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.has(key) {
 		return nil
 	}
 
@@ -120,7 +139,10 @@ func (f *Flash) GetBool(key string) bool {
 
 // Del deletes key from flash
 func (f *Flash) Del(key string) {
-	if f.Has(key) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.has(key) {
 		f.changed = true
 	}
 	delete(f.v, key)
@@ -128,6 +150,12 @@ func (f *Flash) Del(key string) {
 
 // Has checks is flash has a given key
 func (f *Flash) Has(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.has(key)
+}
+
+func (f *Flash) has(key string) bool {
 	if f.v == nil {
 		return false
 	}
@@ -136,7 +164,10 @@ func (f *Flash) Has(key string) bool {
 
 // Clear deletes all data
 func (f *Flash) Clear() {
-	if f.Count() > 0 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.v) > 0 {
 		f.changed = true
 	}
 	f.v = nil
@@ -144,19 +175,31 @@ func (f *Flash) Clear() {
 
 // Count returns count of flash's keys
 func (f *Flash) Count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	return len(f.v)
 }
 
 // Clone clones flash
 func (f *Flash) Clone() *Flash {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	return &Flash{v: cloneValues(f.v)}
 }
 
 // Changed returns true if value changed
 func (f *Flash) Changed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	return f.changed
 }
 
+func (f *Flash) resetChanged() {
+	f.mu.Lock()
+	f.changed = false
+	f.mu.Unlock()
+}
+
 func cloneValues(src flashData) flashData {
 	n := make(flashData, len(src))
 	for k, vv := range src {
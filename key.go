@@ -4,6 +4,10 @@ const (
 	// manager internal data
 	timestampKey = "_session/timestamp"
 	destroyedKey = "_session/destroyed" // for detect session hijack
+	createdKey   = "_session/created"   // for absolute timeout
+	idKey        = "_session/id"        // for cookie store
+	userKey      = "_session/user"      // for index session by user
+	versionKey   = "_session/version"   // for optimistic concurrency control
 
 	// session internal data
 	flashKey = "_session/flash"
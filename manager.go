@@ -6,14 +6,16 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"net/http"
-	"strings"
 	"time"
 )
 
 // Manager is the session manager
 type Manager struct {
-	config Config
-	hashID func(id string) string
+	config     Config
+	hashID     func(id string) string
+	hashIDWith func(id string, secret []byte) string
+	signer     Signer
+	verifiers  map[string]Signer
 }
 
 // New creates new session manager
@@ -21,6 +23,12 @@ func New(config Config) *Manager {
 	if config.Store == nil {
 		panic("session: nil store")
 	}
+	if _, ok := config.Store.(VersionedStore); config.OptimisticLock && !ok {
+		panic("session: store not support optimistic lock")
+	}
+	if _, ok := config.Store.(PatchStore); config.PartialUpdate && !ok {
+		panic("session: store not support partial update")
+	}
 
 	m := Manager{}
 	m.config = config
@@ -37,18 +45,45 @@ func New(config Config) *Manager {
 		}
 	}
 
+	if len(m.config.Secrets) == 0 {
+		m.config.Secrets = [][]byte{m.config.Secret}
+	}
+
 	if m.config.DisableHashID {
-		m.hashID = func(id string) string {
+		m.hashIDWith = func(id string, _ []byte) string {
 			return id
 		}
 	} else {
-		m.hashID = func(id string) string {
+		m.hashIDWith = func(id string, secret []byte) string {
 			h := sha256.New()
 			h.Write([]byte(id))
-			h.Write(config.Secret)
+			h.Write(secret)
 			return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
 		}
 	}
+	m.hashID = func(id string) string {
+		return m.hashIDWith(id, m.config.Secrets[0])
+	}
+
+	m.signer = m.config.Signer
+	m.verifiers = make(map[string]Signer)
+	if len(m.config.Keys) > 0 {
+		legacy := newSHA1Signer(m.config.Keys)
+		if m.signer == nil {
+			m.signer = legacy
+		}
+		m.verifiers[""] = legacy
+	}
+	for _, v := range m.config.Verifiers {
+		m.verifiers[v.Algorithm()] = v
+	}
+	if m.signer != nil {
+		m.verifiers[m.signer.Algorithm()] = m.signer
+	}
+
+	if m.config.Transport == nil {
+		m.config.Transport = CookieTransport{}
+	}
 
 	if m.config.IdleTimeout <= 0 {
 		m.config.IdleTimeout = m.config.MaxAge
@@ -70,35 +105,36 @@ func (m *Manager) Get(r *http.Request, name string) (*Session, error) {
 		Rolling:  m.config.Rolling,
 	}
 
-	// get session id from cookie
-	cookie, err := r.Cookie(name)
-	if err == nil && len(cookie.Value) > 0 {
-		var rawID string
+	if cs, ok := m.config.Store.(CookieStore); ok {
+		return m.getFromCookieStore(r, &s, cs)
+	}
 
-		// verify signature
-		if len(m.config.Keys) > 0 {
-			parts := strings.Split(cookie.Value, ".")
-			rawID = parts[0]
+	// get session id from transport
+	if value := m.config.Transport.Get(r, name); len(value) > 0 {
+		rawID := value
 
-			if len(parts) != 2 || !verify(rawID, parts[1], m.config.Keys) {
+		// verify signature
+		if m.signer != nil {
+			var ok bool
+			rawID, ok = verifyValue(m.verifiers, value)
+			if !ok {
 				goto invalidSignature
 			}
-		} else {
-			rawID = cookie.Value
 		}
 
-		hashedID := m.hashID(rawID)
-
 		// get session data from store
-		s.data, err = m.config.Store.Get(r.Context(), hashedID)
+		data, hashedID, err := m.getData(r.Context(), rawID)
 		if err == nil {
+			s.data = data
 			s.rawID = rawID
 			s.id = hashedID
+			s.rekey = hashedID != m.hashID(rawID)
+			s.version = data.Version()
 		} else if err != ErrNotFound {
 			return nil, err
 		}
 
-		// DO NOT set session id to cookie value if not found in store
+		// DO NOT set session id to request's value if not found in store
 		// to prevent session fixation attack
 	}
 invalidSignature:
@@ -112,53 +148,210 @@ invalidSignature:
 	return &s, nil
 }
 
+// getData gets session data from store,
+// tries hash id with all secrets to support secret rotation
+func (m *Manager) getData(ctx context.Context, rawID string) (Data, string, error) {
+	for i, secret := range m.config.Secrets {
+		if i > 0 && m.config.DisableHashID {
+			break
+		}
+
+		hashedID := m.hashIDWith(rawID, secret)
+		data, err := m.config.Store.Get(ctx, hashedID)
+		if err == nil && m.exceedAbsoluteTimeout(data) {
+			return nil, "", ErrNotFound
+		}
+		if err != ErrNotFound {
+			return data, hashedID, err
+		}
+	}
+	return nil, "", ErrNotFound
+}
+
+// exceedAbsoluteTimeout checks is session data live longer than absolute timeout
+func (m *Manager) exceedAbsoluteTimeout(data Data) bool {
+	if m.config.AbsoluteTimeout <= 0 {
+		return false
+	}
+	created, ok := data[createdKey].(int64)
+	if !ok {
+		return false
+	}
+	return !time.Now().Before(time.Unix(created, 0).Add(m.config.AbsoluteTimeout))
+}
+
+// setTimestamp sets save timestamp and created time for absolute timeout
+func (m *Manager) setTimestamp(s *Session) {
+	now := time.Now().Unix()
+	s.set(timestampKey, now)
+	if m.config.AbsoluteTimeout > 0 && s.get(createdKey) == nil {
+		s.set(createdKey, now)
+	}
+}
+
 // Save saves session to store and set cookie to response
 //
 // Save must be called before response header was written
 func (m *Manager) Save(ctx context.Context, w http.ResponseWriter, s *Session) error {
-	m.setCookie(w, s)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	// detect is flash changed and encode new flash data
 	if s.flash != nil && s.flash.Changed() {
 		b, _ := s.flash.encode()
-		s.Set(flashKey, b)
+		s.set(flashKey, b)
+	}
+
+	var err error
+	if cs, ok := m.config.Store.(CookieStore); ok {
+		err = m.saveToCookieStore(w, s, cs)
+	} else {
+		m.setID(w, s)
+		err = m.saveToStore(ctx, s)
+	}
+	if err != nil {
+		return err
 	}
 
-	// if session modified, then save
-	if s.Changed() {
-		goto save
+	// all changes were saved, prevent save again when call Save multiple times
+	s.changed = false
+	s.dirty = nil
+	if s.flash != nil {
+		s.flash.resetChanged()
 	}
+	return nil
+}
 
+func (m *Manager) saveToStore(ctx context.Context, s *Session) error {
 	// session not modified, and not resave, then do nothing
-	if !m.config.Resave {
+	if !s.isChanged() && !s.rekey && !m.shouldResave(s) {
 		return nil
 	}
 
-	// session not modified, configured to resave but not pass ResaveAfter
-	if lastSave := time.Unix(s.GetInt64(timestampKey), 0); time.Now().Before(lastSave.Add(m.config.ResaveAfter)) {
-		return nil
+	// save session data to store
+	m.setTimestamp(s)
+	if !s.rekey {
+		if m.config.PartialUpdate && !m.config.OptimisticLock && !s.isNew {
+			err := m.patchData(ctx, s)
+			if err != ErrNotFound {
+				return err
+			}
+			// session was deleted from store, save the whole session
+		}
+		return m.setData(ctx, s, s.id)
 	}
 
-save:
-	// save session data to store
-	s.Set(timestampKey, time.Now().Unix())
-	return m.config.Store.Set(ctx, s.id, s.data, makeStoreOption(m, s))
+	// session was found by old secret, move it to current secret
+	id := m.hashID(s.rawID)
+	s.version = 0
+	err := m.setData(ctx, s, id)
+	if err != nil {
+		return err
+	}
+	oldID := s.id
+	s.id = id
+	s.rekey = false
+	return m.config.Store.Del(ctx, oldID)
+}
+
+// maxMergeAttempts is the maximum attempts to merge conflicted session
+const maxMergeAttempts = 5
+
+// setData sets session data to store,
+// checks session version when OptimisticLock enabled
+func (m *Manager) setData(ctx context.Context, s *Session, key string) error {
+	if !m.config.OptimisticLock {
+		return m.config.Store.Set(ctx, key, s.data, makeStoreOption(m, s))
+	}
+
+	vs := m.config.Store.(VersionedStore)
+	for i := 0; ; i++ {
+		prev, hasPrev := s.data[versionKey]
+		s.set(versionKey, s.version+1)
+		err := vs.SetIfVersion(ctx, key, s.data, s.version, makeStoreOption(m, s))
+		if err == nil {
+			s.version++
+			return nil
+		}
+
+		// data was not saved, restore its version
+		if hasPrev {
+			s.data[versionKey] = prev
+		} else {
+			delete(s.data, versionKey)
+		}
+		if err != ErrConflict || m.config.Merge == nil || i >= maxMergeAttempts-1 {
+			return err
+		}
+
+		current, err := m.config.Store.Get(ctx, key)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+		s.data = m.config.Merge(current, s.data)
+		s.version = current.Version()
+	}
+}
+
+// patchData saves only changed keys to store
+func (m *Manager) patchData(ctx context.Context, s *Session) error {
+	set := make(Data)
+	var del []string
+	for k, deleted := range s.dirty {
+		if deleted {
+			del = append(del, k)
+		} else {
+			set[k] = s.data[k]
+		}
+	}
+	return m.config.Store.(PatchStore).Patch(ctx, s.id, set, del, makeStoreOption(m, s))
+}
+
+// shouldResave checks is unmodified session need to save
+func (m *Manager) shouldResave(s *Session) bool {
+	if !m.config.Resave {
+		return false
+	}
+
+	// configured to resave but not pass ResaveAfter
+	lastSaveUnix, _ := s.get(timestampKey).(int64)
+	lastSave := time.Unix(lastSaveUnix, 0)
+	return !time.Now().Before(lastSave.Add(m.config.ResaveAfter))
 }
 
 // Destroy deletes session from store
 func (m *Manager) Destroy(ctx context.Context, s *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := m.config.Store.(CookieStore); ok {
+		// can not delete data from client,
+		// clear session data then cookies will be removed when save
+		s.data = nil
+		s.changed = true
+		return nil
+	}
 	return m.config.Store.Del(ctx, s.id)
 }
 
 // Regenerate regenerates session id
 // use when change user access level to prevent session fixation
 func (m *Manager) Regenerate(ctx context.Context, s *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return m.regenerate(ctx, s)
+}
+
+func (m *Manager) regenerate(ctx context.Context, s *Session) error {
 	id := s.id
 
 	s.rawID = m.config.GenerateID()
 	s.isNew = true
 	s.id = m.hashID(s.rawID)
 	s.changed = true
+	s.rekey = false
+	version := s.version
+	s.version = 0
 
 	if m.config.DeleteOldSession {
 		return m.config.Store.Del(ctx, id)
@@ -167,36 +360,122 @@ func (m *Manager) Regenerate(ctx context.Context, s *Session) error {
 	data := s.data.Clone()
 	data[timestampKey] = int64(0)
 	data[destroyedKey] = time.Now().UnixNano()
+	if m.config.OptimisticLock {
+		// other requests that hold old session will conflict when save
+		data[versionKey] = version + 1
+	}
 	return m.config.Store.Set(ctx, id, data, makeStoreOption(m, s))
 }
 
 // Renew clears session data and regenerate new session id
 func (m *Manager) Renew(ctx context.Context, s *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.data = make(Data)
-	return m.Regenerate(ctx, s)
+	return m.regenerate(ctx, s)
+}
+
+// AssociateUser associates session with user id,
+// associated sessions can be listed and destroyed by user id.
+// Empty user id removes association.
+//
+// Store must implement UserStore
+func (m *Manager) AssociateUser(s *Session, userID string) error {
+	if _, ok := m.config.Store.(UserStore); !ok {
+		return ErrNotSupported
+	}
+
+	if userID == "" {
+		s.Del(userKey)
+		return nil
+	}
+	s.Set(userKey, userID)
+	return nil
+}
+
+// ListUserSessions returns ids of all active sessions that associated with user id
+//
+// Store must implement UserStore
+func (m *Manager) ListUserSessions(ctx context.Context, userID string) ([]string, error) {
+	return m.userSessions(ctx, userID, false)
+}
+
+// DestroyUserSessions deletes all sessions that associated with user id from store
+//
+// Store must implement UserStore
+func (m *Manager) DestroyUserSessions(ctx context.Context, userID string) error {
+	ids, err := m.userSessions(ctx, userID, true)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		err = m.config.Store.Del(ctx, id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// userSessions returns ids of sessions that still associated with user id,
+// includes regenerated sessions if withDestroyed is true
+func (m *Manager) userSessions(ctx context.Context, userID string, withDestroyed bool) ([]string, error) {
+	us, ok := m.config.Store.(UserStore)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	keys, err := us.UserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// index may contain expired or re-associated sessions
+	var ids []string
+	for _, k := range keys {
+		data, err := m.config.Store.Get(ctx, k)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if u, _ := data[userKey].(string); u != userID {
+			continue
+		}
+		if _, destroyed := data[destroyedKey]; destroyed && !withDestroyed {
+			continue
+		}
+		ids = append(ids, k)
+	}
+	return ids, nil
 }
 
-func (m *Manager) setCookie(w http.ResponseWriter, s *Session) {
-	// if session don't have raw id, don't set cookie
+func (m *Manager) setID(w http.ResponseWriter, s *Session) {
+	// if session don't have raw id, don't set session id
 	if len(s.rawID) == 0 {
 		return
 	}
 
-	if s.isNew && !s.Changed() {
+	if s.isNew && !s.isChanged() {
 		return
 	}
-	if !s.Rolling && (!s.isNew || !s.Changed()) {
+	if !s.Rolling && (!s.isNew || !s.isChanged()) {
 		return
 	}
 
 	value := s.rawID
-	if len(m.config.Keys) > 0 {
-		digest := sign(value, m.config.Keys[0])
-		value += "." + digest
+	if m.signer != nil {
+		value = signValue(m.signer, value)
 	}
 
-	cs := http.Cookie{
-		Name:     s.Name,
+	m.config.Transport.Set(w, s, value)
+}
+
+func makeCookie(s *Session, name, value string) *http.Cookie {
+	c := http.Cookie{
+		Name:     name,
 		Domain:   s.Domain,
 		Path:     s.Path,
 		HttpOnly: s.HTTPOnly,
@@ -205,11 +484,68 @@ func (m *Manager) setCookie(w http.ResponseWriter, s *Session) {
 		SameSite: s.SameSite,
 	}
 	if s.MaxAge > 0 {
-		cs.MaxAge = int(s.MaxAge / time.Second)
-		cs.Expires = time.Now().Add(s.MaxAge)
+		c.MaxAge = int(s.MaxAge / time.Second)
+		c.Expires = time.Now().Add(s.MaxAge)
+	}
+	return &c
+}
+
+func (m *Manager) getFromCookieStore(r *http.Request, s *Session, cs CookieStore) (*Session, error) {
+	var value string
+	value, s.chunks = readCookieChunks(r, s.Name)
+	if len(value) > 0 {
+		data, err := cs.DecodeCookie(s.Name, value)
+		if err == nil && m.exceedAbsoluteTimeout(data) {
+			err = ErrNotFound
+		}
+		if err == nil {
+			s.data = data
+			s.rawID = s.GetString(idKey)
+		} else if err != ErrNotFound {
+			return nil, err
+		}
+	}
+
+	if len(s.rawID) == 0 {
+		s.data = nil
+		s.rawID = m.config.GenerateID()
+		s.isNew = true
 	}
+	s.id = m.hashID(s.rawID)
+
+	return s, nil
+}
+
+func (m *Manager) saveToCookieStore(w http.ResponseWriter, s *Session, cs CookieStore) error {
+	if s.isNew && !s.isChanged() {
+		return nil
+	}
+	if !s.isChanged() && !s.Rolling && !m.shouldResave(s) {
+		return nil
+	}
+
+	// session was cleared, remove all cookies
+	if len(s.data) == 0 {
+		removeCookieChunks(w, s, 0)
+		return nil
+	}
+
+	s.set(idKey, s.rawID)
+	m.setTimestamp(s)
+	value, err := cs.EncodeCookie(s.Name, s.data, makeStoreOption(m, s))
+	if err != nil {
+		return err
+	}
+
+	chunks := splitCookieChunks(value)
+	for i, v := range chunks {
+		http.SetCookie(w, makeCookie(s, chunkCookieName(s.Name, i), v))
+	}
+
+	// remove stale chunks from previous larger session
+	removeCookieChunks(w, s, len(chunks))
 
-	http.SetCookie(w, &cs)
+	return nil
 }
 
 func (m *Manager) isSecure(r *http.Request) bool {
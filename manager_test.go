@@ -1,6 +1,7 @@
 package session_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -113,4 +114,276 @@ func TestManagerNotPassMiddleware(t *testing.T) {
 			assert.Error(t, s.Destroy())
 		}
 	})
+
+	t.Run("Save", func(t *testing.T) {
+		s, err := m.Get(r, sessName)
+		if assert.NoError(t, err) {
+			assert.Error(t, s.Save())
+		}
+	})
+}
+
+func TestManagerUserSessions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	m := session.New(session.Config{
+		MaxAge: time.Minute,
+		Store:  new(store.Memory),
+	})
+
+	login := func(userID string) *http.Cookie {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		s, _ := m.Get(r, sessName)
+		assert.NoError(t, m.AssociateUser(s, userID))
+		assert.Equal(t, userID, s.UserID())
+		assert.NoError(t, m.Save(ctx, w, s))
+		return w.Result().Cookies()[0]
+	}
+
+	c1 := login("user1")
+	login("user1")
+	login("user2")
+
+	ids, err := m.ListUserSessions(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Len(t, ids, 2)
+
+	// regenerated session must not be listed
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(c1)
+	s, _ := m.Get(r, sessName)
+	assert.False(t, s.IsNew())
+	assert.NoError(t, m.Regenerate(ctx, s))
+	assert.NoError(t, m.Save(ctx, httptest.NewRecorder(), s))
+
+	ids, err = m.ListUserSessions(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Len(t, ids, 2)
+
+	assert.NoError(t, m.DestroyUserSessions(ctx, "user1"))
+
+	ids, err = m.ListUserSessions(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(c1)
+	s, _ = m.Get(r, sessName)
+	assert.True(t, s.IsNew(), "expected old session destroyed")
+
+	ids, err = m.ListUserSessions(ctx, "user2")
+	assert.NoError(t, err)
+	assert.Len(t, ids, 1)
+}
+
+func TestManagerUserSessionsNotSupported(t *testing.T) {
+	t.Parallel()
+
+	m := session.New(session.Config{
+		Store: &mockStore{},
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	s, _ := m.Get(r, sessName)
+	assert.Equal(t, session.ErrNotSupported, m.AssociateUser(s, "user1"))
+
+	_, err := m.ListUserSessions(context.Background(), "user1")
+	assert.Equal(t, session.ErrNotSupported, err)
+	assert.Equal(t, session.ErrNotSupported, m.DestroyUserSessions(context.Background(), "user1"))
+}
+
+func TestManagerOptimisticLock(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("Conflict", func(t *testing.T) {
+		m := session.New(session.Config{
+			MaxAge:         time.Minute,
+			Store:          new(store.Memory),
+			OptimisticLock: true,
+		})
+
+		w := httptest.NewRecorder()
+		s, _ := m.Get(httptest.NewRequest(http.MethodGet, "/", nil), sessName)
+		s.Set("a", 1)
+		assert.NoError(t, m.Save(ctx, w, s))
+		c := w.Result().Cookies()[0]
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(c)
+		s1, _ := m.Get(r, sessName)
+		s2, _ := m.Get(r, sessName)
+
+		s1.Set("b", 2)
+		assert.NoError(t, m.Save(ctx, httptest.NewRecorder(), s1))
+
+		s2.Set("c", 3)
+		assert.Equal(t, session.ErrConflict, m.Save(ctx, httptest.NewRecorder(), s2))
+		assert.Equal(t, int64(1), s2.Get("_session/version"), "expected version restored")
+
+		s, _ = m.Get(r, sessName)
+		assert.Equal(t, 2, s.GetInt("b"))
+		assert.Nil(t, s.Get("c"))
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		m := session.New(session.Config{
+			MaxAge:         time.Minute,
+			Store:          new(store.Memory),
+			OptimisticLock: true,
+			Merge: func(current, data session.Data) session.Data {
+				for k, v := range data {
+					if _, ok := current[k]; !ok {
+						current[k] = v
+					}
+				}
+				return current
+			},
+		})
+
+		w := httptest.NewRecorder()
+		s, _ := m.Get(httptest.NewRequest(http.MethodGet, "/", nil), sessName)
+		s.Set("a", 1)
+		assert.NoError(t, m.Save(ctx, w, s))
+		c := w.Result().Cookies()[0]
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(c)
+		s1, _ := m.Get(r, sessName)
+		s2, _ := m.Get(r, sessName)
+
+		s1.Set("b", 2)
+		assert.NoError(t, m.Save(ctx, httptest.NewRecorder(), s1))
+
+		s2.Set("c", 3)
+		assert.NoError(t, m.Save(ctx, httptest.NewRecorder(), s2))
+
+		s, _ = m.Get(r, sessName)
+		assert.Equal(t, 1, s.GetInt("a"))
+		assert.Equal(t, 2, s.GetInt("b"))
+		assert.Equal(t, 3, s.GetInt("c"))
+	})
+
+	t.Run("Regenerate", func(t *testing.T) {
+		m := session.New(session.Config{
+			MaxAge:         time.Minute,
+			Store:          new(store.Memory),
+			OptimisticLock: true,
+		})
+
+		w := httptest.NewRecorder()
+		s, _ := m.Get(httptest.NewRequest(http.MethodGet, "/", nil), sessName)
+		s.Set("a", 1)
+		assert.NoError(t, m.Save(ctx, w, s))
+		c := w.Result().Cookies()[0]
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(c)
+		s1, _ := m.Get(r, sessName)
+		s2, _ := m.Get(r, sessName)
+
+		assert.NoError(t, m.Regenerate(ctx, s1))
+		assert.NoError(t, m.Save(ctx, httptest.NewRecorder(), s1))
+
+		s2.Set("b", 2)
+		assert.Equal(t, session.ErrConflict, m.Save(ctx, httptest.NewRecorder(), s2), "expected old session conflict")
+	})
+}
+
+func TestManagerOptimisticLockNotSupported(t *testing.T) {
+	t.Parallel()
+
+	assert.Panics(t, func() {
+		session.New(session.Config{
+			Store:          &mockStore{},
+			OptimisticLock: true,
+		})
+	})
+}
+
+func TestManagerPartialUpdate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	m := session.New(session.Config{
+		MaxAge:        time.Minute,
+		Store:         new(store.Memory),
+		PartialUpdate: true,
+	})
+
+	w := httptest.NewRecorder()
+	s, _ := m.Get(httptest.NewRequest(http.MethodGet, "/", nil), sessName)
+	s.Set("a", 1)
+	s.Set("b", 2)
+	assert.NoError(t, m.Save(ctx, w, s))
+	c := w.Result().Cookies()[0]
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(c)
+	s1, _ := m.Get(r, sessName)
+	s2, _ := m.Get(r, sessName)
+
+	s1.Set("a", 10)
+	s1.Set("c", 3)
+	assert.NoError(t, m.Save(ctx, httptest.NewRecorder(), s1))
+
+	s2.Del("b")
+	s2.Set("d", 4)
+	assert.NoError(t, m.Save(ctx, httptest.NewRecorder(), s2))
+
+	s, _ = m.Get(r, sessName)
+	assert.Equal(t, 10, s.GetInt("a"))
+	assert.Nil(t, s.Get("b"))
+	assert.Equal(t, 3, s.GetInt("c"))
+	assert.Equal(t, 4, s.GetInt("d"))
+
+	// session was deleted from store, save the whole session
+	assert.NoError(t, m.Destroy(ctx, s2))
+	s2.Set("e", 5)
+	assert.NoError(t, m.Save(ctx, httptest.NewRecorder(), s2))
+
+	s, _ = m.Get(r, sessName)
+	assert.Equal(t, 4, s.GetInt("d"))
+	assert.Equal(t, 5, s.GetInt("e"))
+}
+
+func TestManagerPartialUpdateNotSupported(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	encrypted := &store.Encrypted{
+		Store: new(store.Memory),
+		Keys:  []store.EncryptionKey{{ID: "1", Key: []byte("0123456789abcdef")}},
+	}
+
+	assert.Panics(t, func() {
+		session.New(session.Config{
+			Store:         encrypted,
+			PartialUpdate: true,
+		})
+	})
+
+	m := session.New(session.Config{
+		MaxAge:        time.Minute,
+		Store:         &store.Retry{Store: encrypted},
+		PartialUpdate: true,
+	})
+
+	w := httptest.NewRecorder()
+	s, _ := m.Get(httptest.NewRequest(http.MethodGet, "/", nil), sessName)
+	s.Set("a", 1)
+	assert.NoError(t, m.Save(ctx, w, s))
+	c := w.Result().Cookies()[0]
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(c)
+	s, _ = m.Get(r, sessName)
+	s.Set("b", 2)
+	assert.Equal(t, session.ErrNotSupported, m.Save(ctx, httptest.NewRecorder(), s), "expected wrapped store that can not patch fails")
 }
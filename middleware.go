@@ -6,6 +6,7 @@ import (
 	"errors"
 	"net"
 	"net/http"
+	"sync"
 )
 
 // Errors
@@ -29,14 +30,15 @@ func (m *Manager) Middleware() func(http.Handler) http.Handler {
 			rm := &scopedManager{
 				Manager:        m,
 				ResponseWriter: w,
-				r:              r,
 				storage:        make(map[string]*Session),
 			}
 
+			// error handler gets request that can get sessions
 			ctx := context.WithValue(r.Context(), scopedManagerKey{}, rm)
-			h.ServeHTTP(rm, r.WithContext(ctx))
+			rm.r = r.WithContext(ctx)
+			h.ServeHTTP(rm, rm.r)
 
-			rm.MustSaveAll()
+			rm.saveAll()
 		})
 	}
 }
@@ -48,21 +50,14 @@ func Get(ctx context.Context, name string) (*Session, error) {
 		return nil, ErrNotPassMiddleware
 	}
 
-	// try get session from storage first
-	// to preserve session data from difference handler
-	if s, ok := m.storage[name]; ok {
-		return s, nil
-	}
-
-	// get session from manager
-	s, err := m.Get(name)
+	s, err := m.getSession(name)
 	if err != nil {
+		// error handler may get session, must not hold lock
+		if m.config.ErrorHandler != nil {
+			m.handleError(nil, err)
+		}
 		return nil, err
 	}
-	s.m = m
-
-	// save session to storage for later get
-	m.storage[name] = s
 	return s, nil
 }
 
@@ -73,31 +68,89 @@ type scopedManager struct {
 	http.ResponseWriter
 
 	r           *http.Request
+	mu          sync.Mutex // guards storage, wroteHeader and aborted
 	storage     map[string]*Session
 	wroteHeader bool
+	aborted     bool // error handler wrote response
 }
 
 func (m *scopedManager) Get(name string) (*Session, error) {
 	return m.Manager.Get(m.r, name)
 }
 
+// getSession gets session from storage, or from manager then saves to storage
+func (m *scopedManager) getSession(name string) (*Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// try get session from storage first
+	// to preserve session data from difference handler
+	if s, ok := m.storage[name]; ok {
+		return s, nil
+	}
+
+	// get session from manager
+	s, err := m.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	s.m = m
+
+	// save session to storage for later get
+	m.storage[name] = s
+	return s, nil
+}
+
+// state returns whether header was written and whether error handler wrote response
+func (m *scopedManager) state() (wroteHeader, aborted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.wroteHeader, m.aborted
+}
+
 func (m *scopedManager) Save(s *Session) error {
 	return m.Manager.Save(m.r.Context(), m.ResponseWriter, s)
 }
 
-func (m *scopedManager) MustSaveAll() {
+func (m *scopedManager) saveAll() {
+	m.mu.Lock()
 	if m.wroteHeader {
+		m.mu.Unlock()
 		return
 	}
-
+	ss := make([]*Session, 0, len(m.storage))
 	for _, s := range m.storage {
+		ss = append(ss, s)
+	}
+	m.mu.Unlock()
+
+	for _, s := range ss {
 		err := m.Save(s)
 		if err != nil {
-			panic("session: " + err.Error())
+			m.handleError(s, err)
+		}
+		// error handler wrote response, do not touch headers
+		if _, aborted := m.state(); aborted {
+			return
 		}
 	}
 }
 
+func (m *scopedManager) handleError(s *Session, err error) {
+	if m.config.ErrorHandler == nil {
+		panic("session: " + err.Error())
+	}
+
+	w := errorResponseWriter{ResponseWriter: m.ResponseWriter}
+	m.config.ErrorHandler(&w, m.r, s, err)
+	if w.wroteHeader {
+		m.mu.Lock()
+		m.aborted = true
+		m.wroteHeader = true
+		m.mu.Unlock()
+	}
+}
+
 func (m *scopedManager) Regenerate(s *Session) error {
 	return m.Manager.Regenerate(m.r.Context(), s)
 }
@@ -108,19 +161,30 @@ func (m *scopedManager) Renew(s *Session) error {
 
 // Write implements http.ResponseWriter
 func (m *scopedManager) Write(b []byte) (int, error) {
-	if !m.wroteHeader {
+	if wroteHeader, _ := m.state(); !wroteHeader {
 		m.WriteHeader(http.StatusOK)
 	}
+	if _, aborted := m.state(); aborted {
+		return len(b), nil
+	}
 	return m.ResponseWriter.Write(b)
 }
 
 // WriteHeader implements http.ResponseWriter
 func (m *scopedManager) WriteHeader(code int) {
+	if wroteHeader, _ := m.state(); wroteHeader {
+		return
+	}
+	m.saveAll()
+
+	m.mu.Lock()
 	if m.wroteHeader {
+		// error handler wrote response
+		m.mu.Unlock()
 		return
 	}
-	m.MustSaveAll()
 	m.wroteHeader = true
+	m.mu.Unlock()
 	m.ResponseWriter.WriteHeader(code)
 }
 
@@ -146,3 +210,19 @@ func (m *scopedManager) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	}
 	return nil, nil, http.ErrNotSupported
 }
+
+// errorResponseWriter detects is error handler wrote response
+type errorResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *errorResponseWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *errorResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
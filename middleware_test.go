@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -608,6 +611,69 @@ func TestRollingDisable(t *testing.T) {
 	}
 }
 
+func TestAbsoluteTimeout(t *testing.T) {
+	t.Parallel()
+
+	var (
+		c      int
+		setTTL time.Duration
+	)
+
+	st := new(store.Memory)
+	h := session.Middleware(session.Config{
+		MaxAge:          time.Hour,
+		Rolling:         true,
+		Resave:          true,
+		AbsoluteTimeout: 2 * time.Second,
+		Store: &mockStore{
+			GetFunc: func(key string) (session.Data, error) {
+				return st.Get(context.Background(), key)
+			},
+			SetFunc: func(key string, value session.Data, opt session.StoreOption) error {
+				setTTL = opt.TTL
+				return st.Set(context.Background(), key, value, opt)
+			},
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, _ := session.Get(r.Context(), sessName)
+		switch c {
+		case 0:
+			s.Set("a", 1)
+		case 1:
+			assert.False(t, s.IsNew())
+			assert.Equal(t, 1, s.GetInt("a"))
+		case 2:
+			assert.True(t, s.IsNew(), "expected session exceeded absolute timeout to be new")
+			assert.Nil(t, s.Get("a"))
+		}
+		c++
+		w.Write([]byte("ok"))
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(w, r)
+	assert.True(t, setTTL > 0 && setTTL <= 2*time.Second, "expected store ttl capped by absolute timeout")
+
+	cs := w.Result().Cookies()
+	if !assert.Len(t, cs, 1) {
+		return
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cs[0])
+	h.ServeHTTP(w, r)
+
+	time.Sleep(2100 * time.Millisecond)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cs[0])
+	h.ServeHTTP(w, r)
+	assert.Equal(t, 3, c)
+}
+
 func TestDestroy(t *testing.T) {
 	t.Parallel()
 
@@ -887,6 +953,557 @@ func TestSignature(t *testing.T) {
 	}
 }
 
+func TestSignerMigration(t *testing.T) {
+	t.Parallel()
+
+	c := 0
+
+	s := new(store.Memory)
+	hh := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, _ := session.Get(r.Context(), sessName)
+		if c == 0 {
+			s.Set("a", 1)
+			c++
+		} else if c == 999 {
+			assert.True(t, s.IsNew())
+			assert.Nil(t, s.Get("a"))
+		} else {
+			assert.Equal(t, 1, s.GetInt("a"))
+		}
+		w.Write([]byte("ok"))
+	})
+
+	h := session.Middleware(session.Config{
+		Keys: [][]byte{
+			[]byte("key1"),
+		},
+		Store: s,
+	})(hh)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(w, r)
+
+	cs := w.Result().Cookies()
+	if !assert.Len(t, cs, 1) {
+		return
+	}
+
+	h = session.Middleware(session.Config{
+		Keys: [][]byte{
+			[]byte("key1"),
+		},
+		Signer:  session.NewHMACSHA256Signer([]byte("key2")),
+		Store:   s,
+		Rolling: true,
+	})(hh)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cs[0])
+	h.ServeHTTP(w, r)
+
+	cs1 := w.Result().Cookies()
+	if !assert.Len(t, cs1, 1) {
+		return
+	}
+	assert.Contains(t, cs1[0].Value, ".hs256.", "expected cookie signed by new algorithm")
+
+	h = session.Middleware(session.Config{
+		Signer:    session.NewHMACSHA512Signer([]byte("key3")),
+		Verifiers: []session.Signer{session.NewHMACSHA256Signer([]byte("key2"))},
+		Store:     s,
+	})(hh)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cs1[0])
+	h.ServeHTTP(w, r)
+
+	// old sha1 cookie no longer valid
+	c = 999
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cs[0])
+	h.ServeHTTP(w, r)
+}
+
+func TestSecretRotation(t *testing.T) {
+	t.Parallel()
+
+	var (
+		c       int
+		setKeys []string
+		delKeys []string
+	)
+
+	st := new(store.Memory)
+	ms := &mockStore{
+		GetFunc: func(key string) (session.Data, error) {
+			return st.Get(context.Background(), key)
+		},
+		SetFunc: func(key string, value session.Data, opt session.StoreOption) error {
+			setKeys = append(setKeys, key)
+			return st.Set(context.Background(), key, value, opt)
+		},
+		DelFunc: func(key string) error {
+			delKeys = append(delKeys, key)
+			return st.Del(context.Background(), key)
+		},
+	}
+
+	hh := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, _ := session.Get(r.Context(), sessName)
+		if c == 0 {
+			s.Set("a", 1)
+		} else {
+			assert.False(t, s.IsNew())
+			assert.Equal(t, 1, s.GetInt("a"))
+		}
+		c++
+		w.Write([]byte("ok"))
+	})
+
+	h := session.Middleware(session.Config{
+		Secret: []byte("secret1"),
+		Store:  ms,
+	})(hh)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(w, r)
+
+	cs := w.Result().Cookies()
+	if !assert.Len(t, cs, 1) || !assert.Len(t, setKeys, 1) {
+		return
+	}
+	oldKey := setKeys[0]
+
+	h = session.Middleware(session.Config{
+		Secrets: [][]byte{[]byte("secret2"), []byte("secret1")},
+		Store:   ms,
+	})(hh)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cs[0])
+	h.ServeHTTP(w, r)
+	if assert.Len(t, setKeys, 2, "expected session re-keyed to current secret") {
+		assert.NotEqual(t, oldKey, setKeys[1])
+	}
+	assert.Equal(t, []string{oldKey}, delKeys, "expected old key deleted")
+	assert.Empty(t, w.Result().Cookies(), "expected cookie not changed")
+
+	h = session.Middleware(session.Config{
+		Secrets: [][]byte{[]byte("secret2")},
+		Store:   ms,
+	})(hh)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cs[0])
+	h.ServeHTTP(w, r)
+	assert.Len(t, setKeys, 2)
+	assert.Equal(t, 3, c)
+}
+
+func TestHeaderTransport(t *testing.T) {
+	t.Parallel()
+
+	c := 0
+
+	h := session.Middleware(session.Config{
+		Keys: [][]byte{
+			[]byte("key1"),
+		},
+		Transport: &session.HeaderTransport{
+			Header: "X-Session",
+		},
+		Store: new(store.Memory),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, _ := session.Get(r.Context(), sessName)
+		switch c {
+		case 0:
+			s.Set("a", 1)
+		case 1:
+			assert.False(t, s.IsNew())
+			assert.Equal(t, 1, s.GetInt("a"))
+			s.Regenerate()
+		}
+		c++
+		w.Write([]byte("ok"))
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(w, r)
+
+	assert.Empty(t, w.Result().Cookies())
+	id := w.Header().Get("X-Session")
+	assert.Contains(t, id, ".", "expected session id was signed")
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Session", id)
+	h.ServeHTTP(w, r)
+
+	newID := w.Header().Get("X-Session")
+	assert.NotEmpty(t, newID, "expected regenerated id emitted in response header")
+	assert.NotEqual(t, id, newID)
+	assert.Equal(t, 2, c)
+}
+
+func TestBearerTransport(t *testing.T) {
+	t.Parallel()
+
+	c := 0
+
+	h := session.Middleware(session.Config{
+		Transport: session.BearerTransport,
+		Store:     new(store.Memory),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, _ := session.Get(r.Context(), sessName)
+		switch c {
+		case 0:
+			s.Set("a", 1)
+		case 1:
+			assert.False(t, s.IsNew())
+			assert.Equal(t, 1, s.GetInt("a"))
+		case 2:
+			assert.True(t, s.IsNew())
+		}
+		c++
+		w.Write([]byte("ok"))
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(w, r)
+
+	auth := w.Header().Get("Authorization")
+	if !assert.True(t, strings.HasPrefix(auth, "Bearer ")) {
+		return
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "bearer "+strings.TrimPrefix(auth, "Bearer "))
+	h.ServeHTTP(w, r)
+	assert.Empty(t, w.Header().Get("Authorization"))
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Basic "+strings.TrimPrefix(auth, "Bearer "))
+	h.ServeHTTP(w, r)
+	assert.Equal(t, 3, c)
+}
+
+func TestCookieStore(t *testing.T) {
+	t.Parallel()
+
+	c := 0
+
+	h := session.Middleware(session.Config{
+		MaxAge: time.Minute,
+		Store: &store.Cookie{
+			Keys: [][]byte{[]byte("0123456789abcdef")},
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, _ := session.Get(r.Context(), sessName)
+		switch c {
+		case 0:
+			assert.True(t, s.IsNew())
+			s.Set("a", strings.Repeat("a", 6000))
+		case 1:
+			assert.False(t, s.IsNew())
+			assert.Equal(t, strings.Repeat("a", 6000), s.GetString("a"))
+			s.Set("a", "1")
+		case 2:
+			assert.False(t, s.IsNew())
+			assert.Equal(t, "1", s.GetString("a"))
+			s.Destroy()
+		}
+		c++
+		w.Write([]byte("ok"))
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(w, r)
+
+	cs := w.Result().Cookies()
+	if !assert.Equal(t, 3, len(cs), "expected large session split into chunks") {
+		return
+	}
+	assert.Equal(t, sessName, cs[0].Name)
+	assert.Equal(t, sessName+"_1", cs[1].Name)
+	assert.Equal(t, sessName+"_2", cs[2].Name)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, x := range cs {
+		r.AddCookie(x)
+	}
+	h.ServeHTTP(w, r)
+
+	cs = w.Result().Cookies()
+	if !assert.Equal(t, 3, len(cs)) {
+		return
+	}
+	assert.NotEmpty(t, cs[0].Value)
+	assert.Equal(t, -1, cs[1].MaxAge, "expected stale chunk removed")
+	assert.Equal(t, -1, cs[2].MaxAge, "expected stale chunk removed")
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cs[0])
+	h.ServeHTTP(w, r)
+
+	cs = w.Result().Cookies()
+	if assert.Len(t, cs, 1) {
+		assert.Equal(t, -1, cs[0].MaxAge, "expected destroyed session remove cookie")
+	}
+	assert.Equal(t, 3, c)
+}
+
+func TestErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	var handled error
+
+	h := session.Middleware(session.Config{
+		Store: &mockStore{
+			SetFunc: func(key string, value session.Data, opt session.StoreOption) error {
+				return fmt.Errorf("store error")
+			},
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, s *session.Session, err error) {
+			assert.NotNil(t, s)
+			handled = err
+			http.Error(w, "session error", http.StatusServiceUnavailable)
+		},
+	})(mockHandler)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(w, r)
+	assert.EqualError(t, handled, "store error")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "session error\n", w.Body.String(), "expected handler response discarded")
+}
+
+func TestErrorHandlerStopSave(t *testing.T) {
+	t.Parallel()
+
+	var handled, saved int
+
+	h := session.Middleware(session.Config{
+		Store: &mockStore{
+			SetFunc: func(key string, value session.Data, opt session.StoreOption) error {
+				saved++
+				return fmt.Errorf("store error")
+			},
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, s *session.Session, err error) {
+			handled++
+			http.Error(w, "session error", http.StatusServiceUnavailable)
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s1, _ := session.Get(r.Context(), "sess1")
+		s1.Set("a", 1)
+		s2, _ := session.Get(r.Context(), "sess2")
+		s2.Set("a", 1)
+		w.Write([]byte("ok"))
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(w, r)
+	assert.Equal(t, 1, handled)
+	assert.Equal(t, 1, saved, "expected remaining sessions not saved")
+	assert.LessOrEqual(t, len(w.Result().Cookies()), 1, "expected remaining sessions not set cookie")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestErrorHandlerContinue(t *testing.T) {
+	t.Parallel()
+
+	called := false
+
+	h := session.Middleware(session.Config{
+		Store: &mockStore{
+			SetFunc: func(key string, value session.Data, opt session.StoreOption) error {
+				return fmt.Errorf("store error")
+			},
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, s *session.Session, err error) {
+			called = true
+		},
+	})(mockHandler)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(w, r)
+	assert.True(t, called)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ok", w.Body.String())
+}
+
+func TestErrorHandlerGet(t *testing.T) {
+	t.Parallel()
+
+	var handled error
+
+	h := session.Middleware(session.Config{
+		Store: &mockStore{
+			GetFunc: func(key string) (session.Data, error) {
+				return nil, fmt.Errorf("store error")
+			},
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, s *session.Session, err error) {
+			assert.Nil(t, s)
+			handled = err
+			w.WriteHeader(http.StatusServiceUnavailable)
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, err := session.Get(r.Context(), sessName)
+		assert.Nil(t, s)
+		assert.Error(t, err)
+		w.Write([]byte("ok"))
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Cookie", sessName+"=test")
+	h.ServeHTTP(w, r)
+	assert.EqualError(t, handled, "store error")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestErrorHandlerGetSession(t *testing.T) {
+	t.Parallel()
+
+	h := session.Middleware(session.Config{
+		Store: &mockStore{
+			GetFunc: func(key string) (session.Data, error) {
+				return nil, fmt.Errorf("store error")
+			},
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, s *session.Session, err error) {
+			// error handler uses other session
+			s, err = session.Get(r.Context(), "other")
+			assert.NoError(t, err)
+			assert.NotNil(t, s)
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session.Get(r.Context(), sessName)
+		w.Write([]byte("ok"))
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Cookie", sessName+"=test")
+		h.ServeHTTP(w, r)
+		assert.Equal(t, "ok", w.Body.String())
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected error handler can get session")
+	}
+}
+
+func TestSessionSave(t *testing.T) {
+	t.Parallel()
+
+	var (
+		setCalled int
+		fail      bool
+	)
+
+	h := session.Middleware(session.Config{
+		Store: &mockStore{
+			SetFunc: func(key string, value session.Data, opt session.StoreOption) error {
+				setCalled++
+				if fail {
+					return fmt.Errorf("store error")
+				}
+				return nil
+			},
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, s *session.Session, err error) {
+			assert.Fail(t, "expected error handler was not called")
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, _ := session.Get(r.Context(), sessName)
+		s.Set("a", 1)
+		if fail {
+			assert.Error(t, s.Save())
+			s.Del("a")
+			fail = false
+			return
+		}
+		assert.NoError(t, s.Save())
+		w.Write([]byte("ok"))
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(w, r)
+	assert.Equal(t, 1, setCalled, "expected saved session not save again")
+	assert.Len(t, w.Result().Cookies(), 1)
+
+	fail = true
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(w, r)
+	assert.Equal(t, 3, setCalled)
+}
+
+func TestConcurrentSession(t *testing.T) {
+	t.Parallel()
+
+	h := session.Middleware(session.Config{
+		Store: new(store.Memory),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				s, err := session.Get(r.Context(), sessName)
+				if !assert.NoError(t, err) {
+					return
+				}
+				s.Set(strconv.Itoa(i), i)
+				s.Flash().Add("a", i)
+				assert.Equal(t, i, s.Get(strconv.Itoa(i)))
+				assert.NoError(t, s.Save())
+				s.ID()
+			}(i)
+		}
+		wg.Wait()
+
+		s, _ := session.Get(r.Context(), sessName)
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, i, s.Get(strconv.Itoa(i)))
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(w, r)
+	assert.NotEmpty(t, w.Result().Cookies())
+}
+
 func TestEmptyBody(t *testing.T) {
 	t.Parallel()
 
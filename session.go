@@ -2,6 +2,7 @@ package session
 
 import (
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -9,13 +10,20 @@ import (
 type Data map[string]interface{}
 
 // Session type
+//
+// Session is safe for concurrent use by multiple goroutines
 type Session struct {
+	mu      sync.RWMutex
 	id      string // id is the hashed id if hash enabled
 	rawID   string
 	data    Data
 	changed bool
 	isNew   bool
 	flash   *Flash
+	chunks  int  // number of cookie chunks from request, for cookie store
+	rekey   bool // session was found by old secret, need to move to current secret
+	version int64
+	dirty   map[string]bool // changed keys since get, value is true if key was deleted
 
 	// cookie config
 	Name     string
@@ -39,25 +47,44 @@ func (data Data) Clone() Data {
 	return r
 }
 
+// Version returns session data version for optimistic concurrency control
+func (data Data) Version() int64 {
+	v, _ := data[versionKey].(int64)
+	return v
+}
+
+// UserID returns user id that associated with session data
+func (data Data) UserID() string {
+	v, _ := data[userKey].(string)
+	return v
+}
+
 // ID returns session id or hashed session id if enable hash id
 func (s *Session) ID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.id
 }
 
 // Changed returns is session data changed
 func (s *Session) Changed() bool {
-	if s.changed {
-		return true
-	}
-	if s.flash != nil && s.flash.Changed() {
-		s.changed = true
-		return true
-	}
-	return false
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.isChanged()
+}
+
+func (s *Session) isChanged() bool {
+	return s.changed || (s.flash != nil && s.flash.Changed())
 }
 
 // Get gets data from session
 func (s *Session) Get(key string) interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.get(key)
+}
+
+func (s *Session) get(key string) interface{} {
 	if s.data == nil {
 		return nil
 	}
@@ -102,26 +129,50 @@ func (s *Session) GetBool(key string) bool {
 
 // Set sets data to session
 func (s *Session) Set(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(key, value)
+}
+
+func (s *Session) set(key string, value interface{}) {
 	if s.data == nil {
 		s.data = make(Data)
 	}
 	s.changed = true
 	s.data[key] = value
+	s.markDirty(key, false)
 }
 
 // Del deletes data from session
 func (s *Session) Del(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.del(key)
+}
+
+func (s *Session) del(key string) {
 	if s.data == nil {
 		return
 	}
 	if _, ok := s.data[key]; ok {
 		s.changed = true
 		delete(s.data, key)
+		s.markDirty(key, true)
+	}
+}
+
+func (s *Session) markDirty(key string, deleted bool) {
+	if s.dirty == nil {
+		s.dirty = make(map[string]bool)
 	}
+	s.dirty[key] = deleted
 }
 
 // Pop gets data from session then delete it
 func (s *Session) Pop(key string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if s.data == nil {
 		return nil
 	}
@@ -130,6 +181,7 @@ func (s *Session) Pop(key string) interface{} {
 	if ok {
 		s.changed = true
 		delete(s.data, key)
+		s.markDirty(key, true)
 	}
 	return r
 }
@@ -172,17 +224,27 @@ func (s *Session) PopBool(key string) bool {
 
 // IsNew checks is new session
 func (s *Session) IsNew() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return s.isNew
 }
 
+// UserID returns user id that associated with session
+func (s *Session) UserID() string {
+	return s.GetString(userKey)
+}
+
 // Flash returns flash from session,
 func (s *Session) Flash() *Flash {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	if s.flash != nil {
 		return s.flash
 	}
 
 	s.flash = new(Flash)
-	if b, ok := s.Get(flashKey).([]byte); ok {
+	if b, ok := s.get(flashKey).([]byte); ok {
 		s.flash.decode(b)
 	}
 	return s.flash
@@ -221,6 +283,17 @@ func (s *Session) Renew() error {
 	return s.m.Renew(s)
 }
 
+// Save saves session to store and sets session id to response,
+// Save returns error instead of passing to ErrorHandler.
+//
+// Can use only with middleware
+func (s *Session) Save() error {
+	if s.m == nil {
+		return ErrNotPassMiddleware
+	}
+	return s.m.Save(s)
+}
+
 // Destroy destroys session from store
 //
 // Can use only with middleware
@@ -1,6 +1,8 @@
 package session
 
 import (
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -65,3 +67,33 @@ func TestSessionGetWithTypes(t *testing.T) {
 	assert.Empty(t, s.PopFloat32("float32"))
 	assert.Empty(t, s.PopFloat64("float64"))
 }
+
+func TestSessionConcurrent(t *testing.T) {
+	t.Parallel()
+
+	s := Session{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			key := strconv.Itoa(i)
+			s.Set(key, i)
+			s.Get(key)
+			s.GetInt(key)
+			s.Changed()
+			s.Flash().Add("a", i)
+			s.Flash().Has("a")
+			s.Pop(key)
+			s.Del(key)
+			s.ID()
+			s.IsNew()
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, s.Flash().Values("a"), 10)
+	assert.True(t, s.Changed())
+}
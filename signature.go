@@ -1,21 +1,151 @@
 package session
 
 import (
+	"crypto/ed25519"
 	"crypto/hmac"
 	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
+	"hash"
+	"strings"
 )
 
-func sign(value string, key []byte) string {
-	h := hmac.New(sha1.New, key)
-	h.Write([]byte(value))
-	digest := h.Sum(nil)
+// Signer signs and verifies session id in cookie
+type Signer interface {
+	// Algorithm returns algorithm tag that put into cookie,
+	// empty tag is reserved for HMAC-SHA1 signature from Config.Keys
+	Algorithm() string
+
+	// Sign signs value and returns signature
+	Sign(value string) string
+
+	// Verify verifies value with signature
+	Verify(value, signature string) bool
+}
+
+type hmacSigner struct {
+	alg  string
+	hash func() hash.Hash
+	keys [][]byte
+}
+
+func (s *hmacSigner) Algorithm() string {
+	return s.alg
+}
+
+func (s *hmacSigner) Sign(value string) string {
+	return sign(s.hash, value, s.keys[0])
+}
+
+func (s *hmacSigner) Verify(value, signature string) bool {
+	return verify(s.hash, value, signature, s.keys)
+}
+
+// NewHMACSHA256Signer creates new HMAC-SHA256 signer,
+// the first key uses to sign, all keys use to verify
+func NewHMACSHA256Signer(keys ...[]byte) Signer {
+	if len(keys) == 0 {
+		panic("session: empty signer keys")
+	}
+	return &hmacSigner{alg: "hs256", hash: sha256.New, keys: keys}
+}
+
+// NewHMACSHA512Signer creates new HMAC-SHA512 signer,
+// the first key uses to sign, all keys use to verify
+func NewHMACSHA512Signer(keys ...[]byte) Signer {
+	if len(keys) == 0 {
+		panic("session: empty signer keys")
+	}
+	return &hmacSigner{alg: "hs512", hash: sha512.New, keys: keys}
+}
+
+// newSHA1Signer creates HMAC-SHA1 signer for Config.Keys
+func newSHA1Signer(keys [][]byte) Signer {
+	return &hmacSigner{hash: sha1.New, keys: keys}
+}
+
+type ed25519Signer struct {
+	privateKey ed25519.PrivateKey
+	publicKeys []ed25519.PublicKey
+}
+
+// NewEd25519Signer creates new Ed25519 signer,
+// the private key uses to sign, its public key and publicKeys use to verify
+func NewEd25519Signer(privateKey ed25519.PrivateKey, publicKeys ...ed25519.PublicKey) Signer {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		panic("session: invalid ed25519 private key")
+	}
+	pub := privateKey.Public().(ed25519.PublicKey)
+	return &ed25519Signer{
+		privateKey: privateKey,
+		publicKeys: append([]ed25519.PublicKey{pub}, publicKeys...),
+	}
+}
+
+func (s *ed25519Signer) Algorithm() string {
+	return "ed25519"
+}
+
+func (s *ed25519Signer) Sign(value string) string {
+	digest := ed25519.Sign(s.privateKey, []byte(value))
+	return base64.RawURLEncoding.EncodeToString(digest)
+}
+
+func (s *ed25519Signer) Verify(value, signature string) bool {
+	digest, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	for _, k := range s.publicKeys {
+		if ed25519.Verify(k, []byte(value), digest) {
+			return true
+		}
+	}
+	return false
+}
+
+// signValue signs value with signer,
+// returns value.signature for empty algorithm or value.algorithm.signature
+func signValue(signer Signer, value string) string {
+	digest := signer.Sign(value)
+	if alg := signer.Algorithm(); alg != "" {
+		return value + "." + alg + "." + digest
+	}
+	return value + "." + digest
+}
+
+// verifyValue verifies signed value with matched algorithm's verifier
+func verifyValue(verifiers map[string]Signer, signed string) (string, bool) {
+	var alg, value, digest string
+
+	parts := strings.Split(signed, ".")
+	switch len(parts) {
+	case 2:
+		value, digest = parts[0], parts[1]
+	case 3:
+		value, alg, digest = parts[0], parts[1], parts[2]
+	default:
+		return "", false
+	}
+
+	v := verifiers[alg]
+	if v == nil || !v.Verify(value, digest) {
+		return "", false
+	}
+	return value, true
+}
+
+func sign(h func() hash.Hash, value string, key []byte) string {
+	m := hmac.New(h, key)
+	m.Write([]byte(value))
+	digest := m.Sum(nil)
 	return base64.RawURLEncoding.EncodeToString(digest)
 }
 
-func verify(value, digest string, keys [][]byte) bool {
+func verify(h func() hash.Hash, value, digest string, keys [][]byte) bool {
 	for _, k := range keys {
-		if hmac.Equal([]byte(digest), []byte(sign(value, k))) {
+		if hmac.Equal([]byte(digest), []byte(sign(h, value, k))) {
 			return true
 		}
 	}
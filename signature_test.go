@@ -0,0 +1,42 @@
+package session_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/moonrhythm/session"
+)
+
+func TestSigner(t *testing.T) {
+	t.Parallel()
+
+	_, priv1, _ := ed25519.GenerateKey(rand.Reader)
+	pub2, priv2, _ := ed25519.GenerateKey(rand.Reader)
+
+	cases := []struct {
+		alg    string
+		signer session.Signer
+		old    session.Signer
+	}{
+		{"hs256", session.NewHMACSHA256Signer([]byte("key2"), []byte("key1")), session.NewHMACSHA256Signer([]byte("key1"))},
+		{"hs512", session.NewHMACSHA512Signer([]byte("key2"), []byte("key1")), session.NewHMACSHA512Signer([]byte("key1"))},
+		{"ed25519", session.NewEd25519Signer(priv1, pub2), session.NewEd25519Signer(priv2)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.alg, func(t *testing.T) {
+			assert.Equal(t, c.alg, c.signer.Algorithm())
+
+			digest := c.signer.Sign("value")
+			assert.True(t, c.signer.Verify("value", digest))
+			assert.False(t, c.signer.Verify("value2", digest))
+			assert.False(t, c.signer.Verify("value", "invalid"))
+
+			assert.True(t, c.signer.Verify("value", c.old.Sign("value")), "expected verify with old key")
+			assert.False(t, c.old.Verify("value", digest))
+		})
+	}
+}
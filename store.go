@@ -13,6 +13,13 @@ var (
 	// ErrNotFound is the error when session not found
 	// store must return ErrNotFound if session data not exists
 	ErrNotFound = errors.New("session: not found")
+
+	// ErrNotSupported is the error when store not supported the operation
+	ErrNotSupported = errors.New("session: operation not supported by store")
+
+	// ErrConflict is the error when session was modified by other request
+	// store must return ErrConflict if session version mismatched
+	ErrConflict = errors.New("session: conflict")
 )
 
 // Store interface
@@ -22,15 +29,66 @@ type Store interface {
 	Del(ctx context.Context, key string) error
 }
 
+// UserStore is the store that indexes sessions by user id
+//
+// Store must add session key into user's index when Set with StoreOption.UserID
+type UserStore interface {
+	Store
+
+	// UserSessions returns keys of sessions that indexed by user id,
+	// result may contain keys of expired or re-associated sessions
+	UserSessions(ctx context.Context, userID string) ([]string, error)
+}
+
+// VersionedStore is the store that supports optimistic concurrency control
+//
+// Store must keep version of stored session from Data.Version
+type VersionedStore interface {
+	Store
+
+	// SetIfVersion sets session data only if version of stored session equals to version,
+	// version of not exists session is 0
+	SetIfVersion(ctx context.Context, key string, value Data, version int64, opt StoreOption) error
+}
+
+// PatchStore is the store that supports partial update
+type PatchStore interface {
+	Store
+
+	// Patch sets and deletes keys in stored session data, other keys remain unchanged.
+	// Must return ErrNotFound if session not exists
+	Patch(ctx context.Context, key string, set Data, del []string, opt StoreOption) error
+}
+
 // StoreOption type
 type StoreOption struct {
 	TTL time.Duration
+
+	// UserID is the user id that associated with session
+	UserID string
 }
 
+// makeStoreOption makes store option from session, caller must hold session's lock
 func makeStoreOption(m *Manager, s *Session) StoreOption {
-	return StoreOption{
-		TTL: m.config.IdleTimeout,
+	userID, _ := s.get(userKey).(string)
+	opt := StoreOption{
+		TTL:    m.config.IdleTimeout,
+		UserID: userID,
 	}
+
+	// session must not live in store longer than absolute timeout
+	if created, ok := s.get(createdKey).(int64); ok && m.config.AbsoluteTimeout > 0 {
+		remaining := time.Until(time.Unix(created, 0).Add(m.config.AbsoluteTimeout))
+		if remaining < time.Second {
+			// zero ttl means no expiration
+			remaining = time.Second
+		}
+		if opt.TTL <= 0 || remaining < opt.TTL {
+			opt.TTL = remaining
+		}
+	}
+
+	return opt
 }
 
 // StoreCoder interface
@@ -0,0 +1,207 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/moonrhythm/session"
+)
+
+// Bolt stores session data in bbolt database
+// implement by using "go.etcd.io/bbolt" package
+//
+// Session data is stored in Bucket with expires at (unix nanoseconds, 0 is no expire),
+// expiring sessions are indexed by expires at in another bucket,
+// gc walks only expired entries
+type Bolt struct {
+	DB    *bolt.DB
+	Coder session.StoreCoder
+
+	// Bucket is the bucket name, default is "session"
+	Bucket string
+
+	// OnError is called when gc fails, optional
+	OnError func(err error)
+
+	w worker
+}
+
+func (s *Bolt) coder() session.StoreCoder {
+	if s.Coder == nil {
+		return session.DefaultStoreCoder
+	}
+	return s.Coder
+}
+
+func (s *Bolt) bucket() []byte {
+	if s.Bucket == "" {
+		return []byte("session")
+	}
+	return []byte(s.Bucket)
+}
+
+func (s *Bolt) expiresBucket() []byte {
+	return append(s.bucket(), "_expires"...)
+}
+
+// expiresKey returns index key that sorts by expires at
+func expiresKey(exp int64, key string) []byte {
+	b := make([]byte, 8, 8+len(key))
+	binary.BigEndian.PutUint64(b, uint64(exp))
+	return append(b, key...)
+}
+
+// GCEvery starts gc every given duration until Close
+func (s *Bolt) GCEvery(d time.Duration) *Bolt {
+	return s.GCEveryContext(context.Background(), d)
+}
+
+// GCEveryContext starts gc every given duration until ctx is done or Close
+func (s *Bolt) GCEveryContext(ctx context.Context, d time.Duration) *Bolt {
+	s.w.every(ctx, d, func() {
+		_, err := s.GC()
+		handleError(s.OnError, err)
+	})
+	return s
+}
+
+// Close stops gc and waits for running gc, DB is not closed
+func (s *Bolt) Close() error {
+	s.w.close()
+	return nil
+}
+
+// GC deletes expired sessions, returns number of deleted sessions
+func (s *Bolt) GC() (int, error) {
+	now := time.Now().UnixNano()
+	var n int
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		idx := tx.Bucket(s.expiresBucket())
+		if idx == nil {
+			return nil
+		}
+		b := tx.Bucket(s.bucket())
+
+		c := idx.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.First() {
+			if int64(binary.BigEndian.Uint64(k)) > now {
+				break
+			}
+			err := b.Delete(k[8:])
+			if err != nil {
+				return err
+			}
+			err = c.Delete()
+			if err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Get gets session data from bbolt
+func (s *Bolt) Get(_ context.Context, key string) (session.Data, error) {
+	var sessData session.Data
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket())
+		if b == nil {
+			return session.ErrNotFound
+		}
+
+		v := b.Get([]byte(key))
+		if len(v) < 8 {
+			return session.ErrNotFound
+		}
+		exp := int64(binary.BigEndian.Uint64(v))
+		if exp > 0 && time.Now().UnixNano() >= exp {
+			return session.ErrNotFound
+		}
+
+		// v is valid only in transaction
+		err := s.coder().NewDecoder(bytes.NewReader(v[8:])).Decode(&sessData)
+		if err != nil {
+			return &DecodeError{Err: err}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sessData, nil
+}
+
+// Set sets session data to bbolt
+func (s *Bolt) Set(_ context.Context, key string, value session.Data, opt session.StoreOption) error {
+	var buf bytes.Buffer
+	var exp int64
+	if opt.TTL > 0 {
+		exp = time.Now().Add(opt.TTL).UnixNano()
+	}
+	binary.Write(&buf, binary.BigEndian, exp)
+	err := s.coder().NewEncoder(&buf).Encode(value)
+	if err != nil {
+		return err
+	}
+
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(s.bucket())
+		if err != nil {
+			return err
+		}
+		idx, err := tx.CreateBucketIfNotExists(s.expiresBucket())
+		if err != nil {
+			return err
+		}
+
+		err = s.unindex(b, idx, key)
+		if err != nil {
+			return err
+		}
+		if exp > 0 {
+			err = idx.Put(expiresKey(exp, key), nil)
+			if err != nil {
+				return err
+			}
+		}
+		return b.Put([]byte(key), buf.Bytes())
+	})
+}
+
+// Del deletes session data from bbolt
+func (s *Bolt) Del(_ context.Context, key string) error {
+	return s.DB.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket())
+		if b == nil {
+			return nil
+		}
+
+		err := s.unindex(b, tx.Bucket(s.expiresBucket()), key)
+		if err != nil {
+			return err
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// unindex removes old expires at of key from index
+func (s *Bolt) unindex(b, idx *bolt.Bucket, key string) error {
+	v := b.Get([]byte(key))
+	if len(v) < 8 || idx == nil {
+		return nil
+	}
+	exp := int64(binary.BigEndian.Uint64(v))
+	if exp == 0 {
+		return nil
+	}
+	return idx.Delete(expiresKey(exp, key))
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/moonrhythm/session"
+)
+
+func openBolt(t *testing.T) *bolt.DB {
+	t.Helper()
+
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "session.db"), 0600, nil)
+	if err != nil {
+		t.Fatalf("can not open bolt database: %v", err)
+	}
+	return db
+}
+
+func TestBolt(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	db := openBolt(t)
+	defer db.Close()
+
+	s := &Bolt{DB: db}
+
+	opt := session.StoreOption{TTL: 20 * time.Millisecond}
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	_, err := s.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err)
+	assert.NoError(t, s.Del(ctx, "a"))
+	_, err = s.GC()
+	assert.NoError(t, err)
+
+	err = s.Set(ctx, "a", data, opt)
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	time.Sleep(50 * time.Millisecond)
+	b, err = s.Get(ctx, "a")
+	assert.Nil(t, b, "expected expired key return nil")
+	assert.Equal(t, session.ErrNotFound, err)
+
+	s.Set(ctx, "a", data, session.StoreOption{})
+	b, err = s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	s.Del(ctx, "a")
+	_, err = s.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err)
+}
+
+func TestBoltGC(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	db := openBolt(t)
+	defer db.Close()
+
+	s := &Bolt{DB: db, Bucket: "sess"}
+
+	data := session.Data{"test": "123"}
+	s.Set(ctx, "a", data, session.StoreOption{TTL: time.Millisecond})
+	s.Set(ctx, "b", data, session.StoreOption{TTL: time.Minute})
+	s.Set(ctx, "c", data, session.StoreOption{})
+
+	// renew expiring session, old index entry must be removed
+	s.Set(ctx, "d", data, session.StoreOption{TTL: time.Millisecond})
+	s.Set(ctx, "d", data, session.StoreOption{TTL: time.Minute})
+
+	// deleted session must be removed from index
+	s.Set(ctx, "e", data, session.StoreOption{TTL: time.Millisecond})
+	s.Del(ctx, "e")
+
+	time.Sleep(5 * time.Millisecond)
+	n, err := s.GC()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	db.View(func(tx *bolt.Tx) error {
+		assert.Equal(t, 3, tx.Bucket([]byte("sess")).Stats().KeyN)
+		assert.Equal(t, 2, tx.Bucket([]byte("sess_expires")).Stats().KeyN)
+		return nil
+	})
+
+	for _, k := range []string{"b", "c", "d"} {
+		_, err := s.Get(ctx, k)
+		assert.NoError(t, err)
+	}
+}
@@ -0,0 +1,265 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/moonrhythm/session"
+)
+
+// ErrBreakerOpen is the error when breaker rejects operation and there is no fallback
+var ErrBreakerOpen = errors.New("store: breaker open")
+
+// BreakerState is the state of breaker
+type BreakerState int
+
+// Breaker states
+const (
+	// BreakerClosed passes operations to wrapped store
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen rejects operations without calling wrapped store
+	BreakerOpen
+
+	// BreakerHalfOpen passes one operation at a time to probe wrapped store
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// Breaker stops calling wrapped store after consecutive failures
+//
+// Breaker opens after Threshold consecutive failures,
+// rejects operations until OpenTimeout passed, then probes wrapped store
+// with one operation at a time, closes when probe succeeded.
+//
+// Rejected and failed operations go to Fallback if set,
+// sessions in Fallback are not copied back to wrapped store when breaker closed
+type Breaker struct {
+	Store session.Store
+
+	// Fallback is the store to use while wrapped store is unavailable, optional
+	Fallback session.Store
+
+	// Threshold is the number of consecutive failures to open breaker, default is 5
+	Threshold int
+
+	// OpenTimeout is the duration to reject operations before probe, default is 10 seconds
+	OpenTimeout time.Duration
+
+	// IsFailure reports whether err counts as failure, default is DefaultBreakerFailure.
+	// Canceled context neither counts as failure nor resets failures
+	IsFailure func(err error) bool
+
+	// OnStateChange is called when state changed, optional
+	OnStateChange func(from, to BreakerState)
+
+	m        sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// DefaultBreakerFailure reports whether err means wrapped store is unavailable,
+// session errors, canceled context and decode errors are not failures
+func DefaultBreakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var decodeErr *DecodeError
+	switch {
+	case errors.Is(err, session.ErrNotFound),
+		errors.Is(err, session.ErrConflict),
+		errors.Is(err, session.ErrNotSupported),
+		errors.Is(err, context.Canceled),
+		errors.As(err, &decodeErr):
+		return false
+	}
+	return true
+}
+
+func (s *Breaker) threshold() int {
+	if s.Threshold <= 0 {
+		return 5
+	}
+	return s.Threshold
+}
+
+func (s *Breaker) openTimeout() time.Duration {
+	if s.OpenTimeout <= 0 {
+		return 10 * time.Second
+	}
+	return s.OpenTimeout
+}
+
+func (s *Breaker) isFailure(err error) bool {
+	if s.IsFailure == nil {
+		return DefaultBreakerFailure(err)
+	}
+	return s.IsFailure(err)
+}
+
+// State returns current state
+func (s *Breaker) State() BreakerState {
+	s.m.Lock()
+	defer s.m.Unlock()
+	return s.state
+}
+
+// setState changes state, returns function to notify change, must hold lock
+func (s *Breaker) setState(state BreakerState) func() {
+	from := s.state
+	s.state = state
+	if from == state || s.OnStateChange == nil {
+		return func() {}
+	}
+	return func() { s.OnStateChange(from, state) }
+}
+
+// allow reports whether operation can call wrapped store
+func (s *Breaker) allow() (ok, probe bool) {
+	s.m.Lock()
+	notify := func() {}
+	switch s.state {
+	case BreakerClosed:
+		ok = true
+	case BreakerOpen:
+		if time.Since(s.openedAt) >= s.openTimeout() {
+			notify = s.setState(BreakerHalfOpen)
+			s.probing = true
+			ok, probe = true, true
+		}
+	case BreakerHalfOpen:
+		if !s.probing {
+			s.probing = true
+			ok, probe = true, true
+		}
+	}
+	s.m.Unlock()
+
+	notify()
+	return
+}
+
+// done records result of operation, reports whether err is failure
+func (s *Breaker) done(err error, probe bool) (failed bool) {
+	failed = s.isFailure(err)
+
+	s.m.Lock()
+	notify := func() {}
+	if probe {
+		s.probing = false
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		// operation did not finish, failures are kept and next operation probes again
+	case !failed:
+		s.failures = 0
+		if probe {
+			notify = s.setState(BreakerClosed)
+		}
+	case probe:
+		s.openedAt = time.Now()
+		notify = s.setState(BreakerOpen)
+	case s.state == BreakerClosed:
+		s.failures++
+		if s.failures >= s.threshold() {
+			s.failures = 0
+			s.openedAt = time.Now()
+			notify = s.setState(BreakerOpen)
+		}
+	}
+	s.m.Unlock()
+
+	notify()
+	return
+}
+
+// do calls f with wrapped store when allowed, then calls f with fallback when rejected or failed
+func (s *Breaker) do(f func(store session.Store) error) error {
+	ok, probe := s.allow()
+	if ok {
+		err := f(s.Store)
+		if !s.done(err, probe) || s.Fallback == nil {
+			return err
+		}
+	}
+	if s.Fallback == nil {
+		return ErrBreakerOpen
+	}
+	return f(s.Fallback)
+}
+
+// Get gets session data from wrapped store or fallback
+func (s *Breaker) Get(ctx context.Context, key string) (r session.Data, err error) {
+	err = s.do(func(store session.Store) (err error) {
+		r, err = store.Get(ctx, key)
+		return
+	})
+	return
+}
+
+// Set sets session data to wrapped store or fallback
+func (s *Breaker) Set(ctx context.Context, key string, value session.Data, opt session.StoreOption) error {
+	return s.do(func(store session.Store) error {
+		return store.Set(ctx, key, value, opt)
+	})
+}
+
+// Del deletes session data from wrapped store or fallback
+func (s *Breaker) Del(ctx context.Context, key string) error {
+	return s.do(func(store session.Store) error {
+		return store.Del(ctx, key)
+	})
+}
+
+// SetIfVersion sets session data to wrapped store or fallback only if version matched,
+// returns session.ErrNotSupported if the store is not session.VersionedStore
+func (s *Breaker) SetIfVersion(ctx context.Context, key string, value session.Data, version int64, opt session.StoreOption) error {
+	return s.do(func(store session.Store) error {
+		vs, ok := store.(session.VersionedStore)
+		if !ok {
+			return session.ErrNotSupported
+		}
+		return vs.SetIfVersion(ctx, key, value, version, opt)
+	})
+}
+
+// Patch patches session data in wrapped store or fallback,
+// returns session.ErrNotSupported if the store is not session.PatchStore
+func (s *Breaker) Patch(ctx context.Context, key string, set session.Data, del []string, opt session.StoreOption) error {
+	return s.do(func(store session.Store) error {
+		ps, ok := store.(session.PatchStore)
+		if !ok {
+			return session.ErrNotSupported
+		}
+		return ps.Patch(ctx, key, set, del, opt)
+	})
+}
+
+// UserSessions returns keys of sessions that associated with user id from wrapped store or fallback,
+// returns session.ErrNotSupported if the store is not session.UserStore
+func (s *Breaker) UserSessions(ctx context.Context, userID string) (r []string, err error) {
+	err = s.do(func(store session.Store) (err error) {
+		us, ok := store.(session.UserStore)
+		if !ok {
+			return session.ErrNotSupported
+		}
+		r, err = us.UserSessions(ctx, userID)
+		return
+	})
+	return
+}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/moonrhythm/session"
+)
+
+func TestBreaker(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	var (
+		mu      sync.Mutex
+		changes []string
+	)
+	m := &errStore{err: fmt.Errorf("error")}
+	s := &Breaker{
+		Store:       m,
+		Threshold:   3,
+		OpenTimeout: 20 * time.Millisecond,
+		OnStateChange: func(from, to BreakerState) {
+			mu.Lock()
+			changes = append(changes, from.String()+"->"+to.String())
+			mu.Unlock()
+		},
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := s.Get(ctx, "a")
+		assert.Equal(t, m.err, err)
+	}
+	assert.Equal(t, BreakerOpen, s.State())
+
+	// fail fast
+	_, err := s.Get(ctx, "a")
+	assert.Equal(t, ErrBreakerOpen, err)
+	assert.Equal(t, ErrBreakerOpen, s.Set(ctx, "a", session.Data{}, session.StoreOption{}))
+	assert.Equal(t, ErrBreakerOpen, s.Del(ctx, "a"))
+	assert.Equal(t, 3, m.attempt)
+
+	// probe failed
+	time.Sleep(30 * time.Millisecond)
+	_, err = s.Get(ctx, "a")
+	assert.Equal(t, m.err, err)
+	assert.Equal(t, BreakerOpen, s.State())
+	assert.Equal(t, 4, m.attempt)
+
+	// probe succeeded
+	m.err = session.ErrNotFound
+	time.Sleep(30 * time.Millisecond)
+	_, err = s.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err)
+	assert.Equal(t, BreakerClosed, s.State())
+
+	mu.Lock()
+	assert.Equal(t, []string{
+		"closed->open",
+		"open->half-open",
+		"half-open->open",
+		"open->half-open",
+		"half-open->closed",
+	}, changes)
+	mu.Unlock()
+}
+
+func TestBreakerNotFailure(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	m := &errStore{}
+	s := &Breaker{Store: m, Threshold: 2}
+
+	for _, err := range []error{
+		fmt.Errorf("error"),
+		session.ErrNotFound,
+		fmt.Errorf("error"),
+		context.Canceled,
+		&DecodeError{Err: fmt.Errorf("bad data")},
+	} {
+		m.err = err
+		s.Get(ctx, "a")
+		assert.Equal(t, BreakerClosed, s.State(), "expected failures reset by %v", err)
+	}
+}
+
+func TestBreakerCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	m := &errStore{}
+	s := &Breaker{Store: m, Threshold: 3}
+
+	for _, err := range []error{
+		fmt.Errorf("error"),
+		fmt.Errorf("error"),
+		context.Canceled,
+	} {
+		m.err = err
+		s.Get(ctx, "a")
+		assert.Equal(t, BreakerClosed, s.State())
+	}
+
+	m.err = fmt.Errorf("error")
+	s.Get(ctx, "a")
+	assert.Equal(t, BreakerOpen, s.State(), "expected canceled not reset failures")
+}
+
+func TestBreakerFallback(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	m := &errStore{err: fmt.Errorf("error")}
+	fallback := new(Memory)
+	s := &Breaker{Store: m, Fallback: fallback, Threshold: 2, OpenTimeout: time.Minute}
+
+	// failed operation goes to fallback
+	err := s.Set(ctx, "a", session.Data{"test": "1"}, session.StoreOption{})
+	assert.NoError(t, err)
+	b, err := fallback.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, session.Data{"test": "1"}, b)
+
+	s.Get(ctx, "a")
+	assert.Equal(t, BreakerOpen, s.State())
+
+	// rejected operation goes to fallback
+	b, err = s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, session.Data{"test": "1"}, b)
+	assert.Equal(t, 2, m.attempt)
+
+	assert.NoError(t, s.Del(ctx, "a"))
+	_, err = fallback.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err)
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	probe := make(chan struct{})
+	m := &blockStore{release: probe}
+	s := &Breaker{Store: m, Threshold: 1, OpenTimeout: time.Millisecond}
+
+	m.err = fmt.Errorf("error")
+	close(m.release)
+	s.Get(ctx, "a")
+	assert.Equal(t, BreakerOpen, s.State())
+	time.Sleep(5 * time.Millisecond)
+
+	// only one probe at a time
+	m.release = make(chan struct{})
+	m.err = nil
+	done := make(chan struct{})
+	go func() {
+		s.Get(ctx, "a")
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return s.State() == BreakerHalfOpen }, time.Second, time.Millisecond)
+	_, err := s.Get(ctx, "a")
+	assert.Equal(t, ErrBreakerOpen, err)
+
+	close(m.release)
+	<-done
+	assert.Equal(t, BreakerClosed, s.State())
+}
+
+// blockStore blocks Get until release closed
+type blockStore struct {
+	errStore
+	release chan struct{}
+}
+
+func (s *blockStore) Get(ctx context.Context, key string) (session.Data, error) {
+	<-s.release
+	return nil, s.err
+}
+
+func TestBreakerForward(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Breaker{Store: new(Memory)}
+	opt := session.StoreOption{TTL: time.Minute, UserID: "user1"}
+
+	err := s.SetIfVersion(ctx, "a", session.Data{"test": "1", "_session/version": int64(1)}, 0, opt)
+	assert.NoError(t, err)
+	err = s.SetIfVersion(ctx, "a", session.Data{"test": "2", "_session/version": int64(1)}, 0, opt)
+	assert.Equal(t, session.ErrConflict, err)
+
+	err = s.Patch(ctx, "a", session.Data{"test": "3"}, nil, opt)
+	assert.NoError(t, err)
+	b, _ := s.Get(ctx, "a")
+	assert.Equal(t, "3", b["test"])
+
+	keys, err := s.UserSessions(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, keys)
+
+	// not supported is not failure
+	s = &Breaker{Store: &errStore{}, Threshold: 1}
+	assert.Equal(t, session.ErrNotSupported, s.SetIfVersion(ctx, "a", session.Data{}, 0, opt))
+	assert.Equal(t, session.ErrNotSupported, s.Patch(ctx, "a", session.Data{}, nil, opt))
+	_, err = s.UserSessions(ctx, "user1")
+	assert.Equal(t, session.ErrNotSupported, err)
+	assert.Equal(t, BreakerClosed, s.State())
+}
@@ -0,0 +1,281 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/moonrhythm/session"
+)
+
+// Cache caches session data from wrapped store in memory,
+// Set and Del write through to wrapped store
+//
+// Cached session data lives at most TTL, use Invalidator to invalidate
+// cached session data in other instances that share the same wrapped store
+type Cache struct {
+	Store session.Store
+
+	// Size is the maximum number of cached sessions, default is 1024
+	Size int
+
+	// TTL is the maximum duration to cache session data, default is 10 seconds,
+	// session data that read from wrapped store may be served until TTL after it expires
+	TTL time.Duration
+
+	// Invalidator propagates invalidation between instances, optional
+	Invalidator CacheInvalidator
+
+	m     sync.Mutex
+	l     *list.List
+	items map[string]*list.Element
+	gen   uint64 // increases on every write, prevents caching stale data from concurrent get
+	once  sync.Once
+	stop  func()
+}
+
+// CacheInvalidator propagates cache invalidation between instances
+type CacheInvalidator interface {
+	// Invalidate notifies other instances to invalidate key
+	Invalidate(ctx context.Context, key string) error
+
+	// Subscribe calls f when other instances invalidate key,
+	// returns function to stop subscription
+	Subscribe(f func(key string)) (stop func())
+}
+
+type cacheItem struct {
+	key  string
+	data session.Data
+	exp  time.Time
+}
+
+func (s *Cache) size() int {
+	if s.Size <= 0 {
+		return 1024
+	}
+	return s.Size
+}
+
+func (s *Cache) ttl() time.Duration {
+	if s.TTL <= 0 {
+		return 10 * time.Second
+	}
+	return s.TTL
+}
+
+// init starts subscription, must call before access cache
+func (s *Cache) init() {
+	s.once.Do(func() {
+		s.l = list.New()
+		s.items = make(map[string]*list.Element)
+		if s.Invalidator != nil {
+			s.stop = s.Invalidator.Subscribe(s.evict)
+		}
+	})
+}
+
+// Close stops invalidation subscription
+func (s *Cache) Close() error {
+	s.init()
+	if s.stop != nil {
+		s.stop()
+	}
+	return nil
+}
+
+// Get gets session data from cache or wrapped store
+func (s *Cache) Get(ctx context.Context, key string) (session.Data, error) {
+	s.init()
+
+	s.m.Lock()
+	if e := s.items[key]; e != nil {
+		it := e.Value.(*cacheItem)
+		if time.Now().Before(it.exp) {
+			s.l.MoveToFront(e)
+			s.m.Unlock()
+			return it.data.Clone(), nil
+		}
+		s.remove(e)
+	}
+	gen := s.gen
+	s.m.Unlock()
+
+	data, err := s.Store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.m.Lock()
+	if s.gen == gen {
+		s.put(key, data.Clone(), s.ttl())
+	}
+	s.m.Unlock()
+	return data, nil
+}
+
+// Set sets session data to wrapped store then caches
+func (s *Cache) Set(ctx context.Context, key string, value session.Data, opt session.StoreOption) error {
+	return s.write(ctx, key, value, opt, func() error {
+		return s.Store.Set(ctx, key, value, opt)
+	})
+}
+
+// SetIfVersion sets session data to wrapped store only if version matched then caches,
+// returns session.ErrNotSupported if wrapped store is not session.VersionedStore
+func (s *Cache) SetIfVersion(ctx context.Context, key string, value session.Data, version int64, opt session.StoreOption) error {
+	vs, ok := s.Store.(session.VersionedStore)
+	if !ok {
+		return session.ErrNotSupported
+	}
+	return s.write(ctx, key, value, opt, func() error {
+		return vs.SetIfVersion(ctx, key, value, version, opt)
+	})
+}
+
+// Patch patches session data in wrapped store then removes from cache,
+// returns session.ErrNotSupported if wrapped store is not session.PatchStore
+func (s *Cache) Patch(ctx context.Context, key string, set session.Data, del []string, opt session.StoreOption) error {
+	ps, ok := s.Store.(session.PatchStore)
+	if !ok {
+		return session.ErrNotSupported
+	}
+
+	// patched session data is not known without read from wrapped store
+	return s.write(ctx, key, nil, opt, func() error {
+		return ps.Patch(ctx, key, set, del, opt)
+	})
+}
+
+// UserSessions returns keys of sessions that associated with user id from wrapped store,
+// returns session.ErrNotSupported if wrapped store is not session.UserStore
+func (s *Cache) UserSessions(ctx context.Context, userID string) ([]string, error) {
+	us, ok := s.Store.(session.UserStore)
+	if !ok {
+		return nil, session.ErrNotSupported
+	}
+	return us.UserSessions(ctx, userID)
+}
+
+// write calls f to write to wrapped store then caches value,
+// removes key from cache when f failed or value is nil
+func (s *Cache) write(ctx context.Context, key string, value session.Data, opt session.StoreOption, f func() error) error {
+	s.init()
+
+	err := f()
+
+	s.m.Lock()
+	s.gen++
+	if err == nil && value != nil {
+		ttl := s.ttl()
+		if opt.TTL > 0 && opt.TTL < ttl {
+			ttl = opt.TTL
+		}
+		s.put(key, value.Clone(), ttl)
+	} else if e := s.items[key]; e != nil {
+		s.remove(e)
+	}
+	s.m.Unlock()
+
+	if err != nil {
+		return err
+	}
+	return s.invalidate(ctx, key)
+}
+
+// Del deletes session data from wrapped store and cache
+func (s *Cache) Del(ctx context.Context, key string) error {
+	s.init()
+
+	err := s.Store.Del(ctx, key)
+	s.evict(key)
+	if err != nil {
+		return err
+	}
+	return s.invalidate(ctx, key)
+}
+
+func (s *Cache) invalidate(ctx context.Context, key string) error {
+	if s.Invalidator == nil {
+		return nil
+	}
+	return s.Invalidator.Invalidate(ctx, key)
+}
+
+// evict removes key from cache
+func (s *Cache) evict(key string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.gen++
+	if e := s.items[key]; e != nil {
+		s.remove(e)
+	}
+}
+
+// put puts data into cache and evicts least recently used item, must hold lock
+func (s *Cache) put(key string, data session.Data, ttl time.Duration) {
+	it := &cacheItem{key: key, data: data, exp: time.Now().Add(ttl)}
+	if e := s.items[key]; e != nil {
+		e.Value = it
+		s.l.MoveToFront(e)
+		return
+	}
+
+	s.items[key] = s.l.PushFront(it)
+	for s.l.Len() > s.size() {
+		s.remove(s.l.Back())
+	}
+}
+
+// remove removes element from cache, must hold lock
+func (s *Cache) remove(e *list.Element) {
+	s.l.Remove(e)
+	delete(s.items, e.Value.(*cacheItem).key)
+}
+
+// RedisInvalidator propagates cache invalidation through redis pub/sub
+type RedisInvalidator struct {
+	Client  redis.UniversalClient
+	Channel string
+
+	once sync.Once
+	id   string
+}
+
+// instanceID returns random id to ignore own invalidation
+func (i *RedisInvalidator) instanceID() string {
+	i.once.Do(func() {
+		b := make([]byte, 8)
+		rand.Read(b)
+		i.id = hex.EncodeToString(b)
+	})
+	return i.id
+}
+
+// Invalidate publishes key to channel
+func (i *RedisInvalidator) Invalidate(ctx context.Context, key string) error {
+	return i.Client.Publish(ctx, i.Channel, i.instanceID()+":"+key).Err()
+}
+
+// Subscribe subscribes channel and calls f for keys that published by other instances
+func (i *RedisInvalidator) Subscribe(f func(key string)) func() {
+	id := i.instanceID()
+	sub := i.Client.Subscribe(context.Background(), i.Channel)
+	go func() {
+		for msg := range sub.Channel() {
+			from, key, ok := strings.Cut(msg.Payload, ":")
+			if !ok || from == id {
+				continue
+			}
+			f(key)
+		}
+	}()
+	return func() { sub.Close() }
+}
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/moonrhythm/session"
+)
+
+func TestCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	backend := new(Memory)
+	s := &Cache{Store: backend, TTL: time.Minute}
+
+	opt := session.StoreOption{TTL: time.Second}
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	_, err := s.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err)
+
+	err = s.Set(ctx, "a", data, opt)
+	assert.NoError(t, err)
+
+	// write through
+	b, err := backend.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "123", b["test"])
+
+	// cached
+	backend.Set(ctx, "a", session.Data{"test": "456"}, opt)
+	b, err = s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "123", b["test"])
+
+	// returned data does not modify cache
+	b["test"] = "789"
+	b, err = s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "123", b["test"])
+
+	err = s.Del(ctx, "a")
+	assert.NoError(t, err)
+	_, err = s.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err)
+	_, err = backend.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err)
+
+	// read through
+	backend.Set(ctx, "b", session.Data{"test": "b"}, opt)
+	b, err = s.Get(ctx, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", b["test"])
+
+	// cache expires with session
+	s.Set(ctx, "c", session.Data{"test": "c"}, opt)
+	time.Sleep(1100 * time.Millisecond)
+	_, err = s.Get(ctx, "c")
+	assert.Equal(t, session.ErrNotFound, err)
+}
+
+func TestCacheTTL(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	backend := new(Memory)
+	s := &Cache{Store: backend, TTL: 100 * time.Millisecond}
+
+	s.Set(ctx, "a", session.Data{"test": "1"}, session.StoreOption{})
+	backend.Set(ctx, "a", session.Data{"test": "2"}, session.StoreOption{})
+
+	b, _ := s.Get(ctx, "a")
+	assert.Equal(t, "1", b["test"])
+
+	time.Sleep(150 * time.Millisecond)
+	b, _ = s.Get(ctx, "a")
+	assert.Equal(t, "2", b["test"])
+}
+
+func TestCacheSize(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	backend := new(Memory)
+	s := &Cache{Store: backend, Size: 2, TTL: time.Minute}
+
+	s.Set(ctx, "a", session.Data{"test": "a"}, session.StoreOption{})
+	s.Set(ctx, "b", session.Data{"test": "b"}, session.StoreOption{})
+	s.Get(ctx, "a")
+	s.Set(ctx, "c", session.Data{"test": "c"}, session.StoreOption{})
+
+	// b is least recently used
+	s.m.Lock()
+	assert.Len(t, s.items, 2)
+	assert.Contains(t, s.items, "a")
+	assert.NotContains(t, s.items, "b")
+	assert.Contains(t, s.items, "c")
+	s.m.Unlock()
+}
+
+func TestCacheRedisInvalidator(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{
+		Addr: redisAddr(),
+	})
+	backend := new(Memory)
+	channel := "session:cache:" + t.Name()
+
+	s1 := &Cache{Store: backend, TTL: time.Minute, Invalidator: &RedisInvalidator{Client: client, Channel: channel}}
+	defer s1.Close()
+	s2 := &Cache{Store: backend, TTL: time.Minute, Invalidator: &RedisInvalidator{Client: client, Channel: channel}}
+	defer s2.Close()
+
+	// wait for subscriptions
+	s1.Get(ctx, "a")
+	s2.Get(ctx, "a")
+	time.Sleep(100 * time.Millisecond)
+
+	err := s1.Set(ctx, "a", session.Data{"test": "1"}, session.StoreOption{})
+	assert.NoError(t, err)
+	b, err := s2.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", b["test"])
+
+	err = s1.Set(ctx, "a", session.Data{"test": "2"}, session.StoreOption{})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		b, err := s2.Get(ctx, "a")
+		return err == nil && b["test"] == "2"
+	}, time.Second, 10*time.Millisecond)
+
+	// own invalidation keeps cache
+	s1.m.Lock()
+	assert.Contains(t, s1.items, "a")
+	s1.m.Unlock()
+}
+
+func TestCacheForward(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	backend := new(Memory)
+	s := &Cache{Store: backend, TTL: time.Minute}
+
+	opt := session.StoreOption{TTL: time.Minute, UserID: "user1"}
+
+	err := s.SetIfVersion(ctx, "a", session.Data{"test": "1", "_session/version": int64(1)}, 0, opt)
+	assert.NoError(t, err)
+	b, _ := s.Get(ctx, "a")
+	assert.Equal(t, "1", b["test"])
+
+	// conflict removes cached data
+	backend.Set(ctx, "a", session.Data{"test": "2", "_session/version": int64(2)}, opt)
+	err = s.SetIfVersion(ctx, "a", session.Data{"test": "3", "_session/version": int64(2)}, 1, opt)
+	assert.Equal(t, session.ErrConflict, err)
+	b, _ = s.Get(ctx, "a")
+	assert.Equal(t, "2", b["test"])
+
+	// patch removes cached data
+	err = s.Patch(ctx, "a", session.Data{"test": "4"}, nil, opt)
+	assert.NoError(t, err)
+	b, _ = s.Get(ctx, "a")
+	assert.Equal(t, "4", b["test"])
+
+	keys, err := s.UserSessions(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, keys)
+
+	s = &Cache{Store: &errStore{}}
+	assert.Equal(t, session.ErrNotSupported, s.SetIfVersion(ctx, "a", session.Data{}, 0, opt))
+	assert.Equal(t, session.ErrNotSupported, s.Patch(ctx, "a", session.Data{}, nil, opt))
+	_, err = s.UserSessions(ctx, "user1")
+	assert.Equal(t, session.ErrNotSupported, err)
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/moonrhythm/session"
+)
+
+// Cookie stores session data in client's cookies,
+// session data is encrypted and authenticated using AES-GCM
+//
+// Cookie does not keep any data on server,
+// Get always returns session.ErrNotFound, Set and Del do nothing
+type Cookie struct {
+	// Keys is the AES keys, key length must be 16, 24, or 32 bytes.
+	// The first key uses to encrypt session data,
+	// all keys use to decrypt to support key rotation
+	Keys  [][]byte
+	Coder session.StoreCoder
+}
+
+func (s *Cookie) coder() session.StoreCoder {
+	if s.Coder == nil {
+		return session.DefaultStoreCoder
+	}
+	return s.Coder
+}
+
+// Get does nothing, session data stores in cookie
+func (s *Cookie) Get(_ context.Context, _ string) (session.Data, error) {
+	return nil, session.ErrNotFound
+}
+
+// Set does nothing, session data stores in cookie
+func (s *Cookie) Set(_ context.Context, _ string, _ session.Data, _ session.StoreOption) error {
+	return nil
+}
+
+// Del does nothing, session data stores in cookie
+func (s *Cookie) Del(_ context.Context, _ string) error {
+	return nil
+}
+
+// EncodeCookie encrypts session data into cookie value
+func (s *Cookie) EncodeCookie(name string, value session.Data, opt session.StoreOption) (string, error) {
+	if len(s.Keys) == 0 {
+		return "", errors.New("store/cookie: empty keys")
+	}
+
+	aead, err := newGCM(s.Keys[0])
+	if err != nil {
+		return "", err
+	}
+
+	// plaintext = expires at (unix seconds, 0 is no expire) + encoded data
+	var buf bytes.Buffer
+	var exp int64
+	if opt.TTL > 0 {
+		exp = time.Now().Add(opt.TTL).Unix()
+	}
+	binary.Write(&buf, binary.BigEndian, exp)
+	err = s.coder().NewEncoder(&buf).Encode(value)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+buf.Len()+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	b := aead.Seal(nonce, nonce, buf.Bytes(), []byte(name))
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCookie decrypts cookie value into session data
+func (s *Cookie) DecodeCookie(name string, value string) (session.Data, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, session.ErrNotFound
+	}
+
+	var plaintext []byte
+	for _, k := range s.Keys {
+		aead, err := newGCM(k)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) < aead.NonceSize() {
+			return nil, session.ErrNotFound
+		}
+
+		nonce, ciphertext := b[:aead.NonceSize()], b[aead.NonceSize():]
+		plaintext, err = aead.Open(nil, nonce, ciphertext, []byte(name))
+		if err == nil {
+			break
+		}
+	}
+	if len(plaintext) < 8 {
+		return nil, session.ErrNotFound
+	}
+
+	exp := int64(binary.BigEndian.Uint64(plaintext))
+	if exp > 0 && time.Now().Unix() >= exp {
+		return nil, session.ErrNotFound
+	}
+
+	var sessData session.Data
+	err = s.coder().NewDecoder(bytes.NewReader(plaintext[8:])).Decode(&sessData)
+	if err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	return sessData, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
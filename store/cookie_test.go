@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/moonrhythm/session"
+)
+
+func TestCookie(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Cookie{
+		Keys: [][]byte{[]byte("0123456789abcdef")},
+	}
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	v, err := s.EncodeCookie("sess", data, session.StoreOption{TTL: time.Second})
+	assert.NoError(t, err)
+
+	b, err := s.DecodeCookie("sess", v)
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	_, err = s.DecodeCookie("other", v)
+	assert.Equal(t, session.ErrNotFound, err, "expected cookie bound to name")
+
+	_, err = s.DecodeCookie("sess", v[:len(v)-2]+"aa")
+	assert.Equal(t, session.ErrNotFound, err, "expected tampered cookie return not found")
+
+	_, err = s.DecodeCookie("sess", "invalid!")
+	assert.Equal(t, session.ErrNotFound, err)
+
+	_, err = s.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err)
+	assert.NoError(t, s.Set(ctx, "a", data, session.StoreOption{}))
+	assert.NoError(t, s.Del(ctx, "a"))
+}
+
+func TestCookieExpired(t *testing.T) {
+	t.Parallel()
+
+	s := &Cookie{
+		Keys: [][]byte{[]byte("0123456789abcdef")},
+	}
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	v, err := s.EncodeCookie("sess", data, session.StoreOption{TTL: time.Millisecond})
+	assert.NoError(t, err)
+
+	time.Sleep(time.Second)
+	_, err = s.DecodeCookie("sess", v)
+	assert.Equal(t, session.ErrNotFound, err, "expected expired cookie return not found")
+}
+
+func TestCookieKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	old := &Cookie{
+		Keys: [][]byte{[]byte("0123456789abcdef")},
+	}
+	v, err := old.EncodeCookie("sess", data, session.StoreOption{})
+	assert.NoError(t, err)
+
+	s := &Cookie{
+		Keys: [][]byte{[]byte("fedcba9876543210"), []byte("0123456789abcdef")},
+	}
+	b, err := s.DecodeCookie("sess", v)
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	s = &Cookie{
+		Keys: [][]byte{[]byte("fedcba9876543210")},
+	}
+	_, err = s.DecodeCookie("sess", v)
+	assert.Equal(t, session.ErrNotFound, err)
+
+	_, err = (&Cookie{}).EncodeCookie("sess", data, session.StoreOption{})
+	assert.Error(t, err, "expected empty keys return error")
+}
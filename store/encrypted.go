@@ -0,0 +1,172 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+
+	"github.com/moonrhythm/session"
+)
+
+// encryptedKey is the data key that holds encrypted envelope in wrapped store
+const encryptedKey = "_session/encrypted"
+
+// Encrypted encrypts session data using AES-GCM before put into wrapped store
+//
+// Wrapped store receives session data that contains only encrypted envelope,
+// envelope contains key id to decrypt with the same key.
+//
+// Encrypted is not session.VersionedStore nor session.PatchStore,
+// wrapped store can not read version or keys from encrypted envelope
+type Encrypted struct {
+	Store session.Store
+	Coder session.StoreCoder
+
+	// Keys is the encryption keys, the first key uses to encrypt,
+	// all keys use to decrypt to support key rotation
+	Keys []EncryptionKey
+
+	// AllowPlaintext returns session data that stored without encryption as is,
+	// uses when enable encryption over existing store, session is encrypted on next save.
+	// Plaintext data is not authenticated, disable after existing sessions expired
+	AllowPlaintext bool
+}
+
+// EncryptionKey is the AES key with id
+type EncryptionKey struct {
+	// ID is the key id put into envelope, must be unique and not longer than 255 bytes
+	ID string
+
+	// Key is the AES key, key length must be 16, 24, or 32 bytes
+	Key []byte
+}
+
+func (s *Encrypted) coder() session.StoreCoder {
+	if s.Coder == nil {
+		return session.DefaultStoreCoder
+	}
+	return s.Coder
+}
+
+// Get gets session data from wrapped store then decrypts
+func (s *Encrypted) Get(ctx context.Context, key string) (session.Data, error) {
+	data, err := s.Store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := data[encryptedKey]; !ok && s.AllowPlaintext {
+		return data, nil
+	}
+
+	b, err := s.open(key, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessData session.Data
+	err = s.coder().NewDecoder(bytes.NewReader(b)).Decode(&sessData)
+	if err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	return sessData, nil
+}
+
+// Set encrypts session data then sets to wrapped store
+func (s *Encrypted) Set(ctx context.Context, key string, value session.Data, opt session.StoreOption) error {
+	var buf bytes.Buffer
+	err := s.coder().NewEncoder(&buf).Encode(value)
+	if err != nil {
+		return err
+	}
+
+	envelope, err := s.seal(key, buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return s.Store.Set(ctx, key, session.Data{encryptedKey: envelope}, opt)
+}
+
+// Del deletes session data from wrapped store
+func (s *Encrypted) Del(ctx context.Context, key string) error {
+	return s.Store.Del(ctx, key)
+}
+
+// seal encrypts plaintext into envelope,
+// envelope = key id length (1 byte) + key id + nonce + ciphertext
+func (s *Encrypted) seal(key string, plaintext []byte) ([]byte, error) {
+	if len(s.Keys) == 0 {
+		return nil, errors.New("store/encrypted: empty keys")
+	}
+
+	k := s.Keys[0]
+	if len(k.ID) > 255 {
+		return nil, errors.New("store/encrypted: key id too long")
+	}
+
+	aead, err := newGCM(k.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, 1+len(k.ID)+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	b = append(b, byte(len(k.ID)))
+	b = append(b, k.ID...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	b = append(b, nonce...)
+
+	// bind ciphertext to session key to prevent swap data between sessions
+	return aead.Seal(b, nonce, plaintext, []byte(key)), nil
+}
+
+// open decrypts envelope from session data
+func (s *Encrypted) open(key string, data session.Data) ([]byte, error) {
+	b, _ := data[encryptedKey].([]byte)
+	if len(b) == 0 {
+		return nil, session.ErrNotFound
+	}
+
+	n := int(b[0])
+	if len(b) < 1+n {
+		return nil, session.ErrNotFound
+	}
+	id, b := string(b[1:1+n]), b[1+n:]
+
+	for _, k := range s.Keys {
+		if k.ID != id {
+			continue
+		}
+
+		aead, err := newGCM(k.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) < aead.NonceSize() {
+			return nil, session.ErrNotFound
+		}
+
+		nonce, ciphertext := b[:aead.NonceSize()], b[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(key))
+		if err != nil {
+			return nil, session.ErrNotFound
+		}
+		return plaintext, nil
+	}
+
+	// key was removed
+	return nil, session.ErrNotFound
+}
+
+// UserSessions returns keys of sessions that associated with user id from wrapped store,
+// returns session.ErrNotSupported if wrapped store is not session.UserStore
+func (s *Encrypted) UserSessions(ctx context.Context, userID string) ([]string, error) {
+	us, ok := s.Store.(session.UserStore)
+	if !ok {
+		return nil, session.ErrNotSupported
+	}
+	return us.UserSessions(ctx, userID)
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/moonrhythm/session"
+)
+
+func TestEncrypted(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	m := new(Memory)
+	s := &Encrypted{
+		Store: m,
+		Keys: []EncryptionKey{
+			{ID: "1", Key: []byte("0123456789abcdef")},
+		},
+	}
+
+	opt := session.StoreOption{}
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	err := s.Set(ctx, "a", data, opt)
+	assert.NoError(t, err)
+
+	raw, err := m.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Nil(t, raw["test"], "expected wrapped store not contain plaintext")
+
+	b, err := s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	// swap data to other session
+	m.Set(ctx, "b", raw, opt)
+	_, err = s.Get(ctx, "b")
+	assert.Equal(t, session.ErrNotFound, err, "expected envelope bound to session key")
+
+	// tamper data
+	envelope := raw[encryptedKey].([]byte)
+	envelope[len(envelope)-1] ^= 1
+	m.Set(ctx, "a", raw, opt)
+	_, err = s.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err)
+
+	s.Set(ctx, "a", data, opt)
+	s.Del(ctx, "a")
+	_, err = s.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err)
+	_, err = m.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err)
+}
+
+func TestEncryptedKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	m := new(Memory)
+	opt := session.StoreOption{}
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	s := &Encrypted{
+		Store: m,
+		Keys: []EncryptionKey{
+			{ID: "1", Key: []byte("0123456789abcdef")},
+		},
+	}
+	err := s.Set(ctx, "a", data, opt)
+	assert.NoError(t, err)
+
+	s = &Encrypted{
+		Store: m,
+		Keys: []EncryptionKey{
+			{ID: "2", Key: []byte("fedcba9876543210")},
+			{ID: "1", Key: []byte("0123456789abcdef")},
+		},
+	}
+	b, err := s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	err = s.Set(ctx, "a", data, opt)
+	assert.NoError(t, err)
+
+	s = &Encrypted{
+		Store: m,
+		Keys: []EncryptionKey{
+			{ID: "2", Key: []byte("fedcba9876543210")},
+		},
+	}
+	b, err = s.Get(ctx, "a")
+	assert.NoError(t, err, "expected data re-encrypted with new key")
+	assert.Equal(t, data, b)
+
+	s = &Encrypted{
+		Store: m,
+		Keys: []EncryptionKey{
+			{ID: "3", Key: []byte("fedcba9876543210")},
+		},
+	}
+	_, err = s.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err, "expected unknown key id return not found")
+}
+
+func TestEncryptedUserSessions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Encrypted{
+		Store: new(Memory),
+		Keys: []EncryptionKey{
+			{ID: "1", Key: []byte("0123456789abcdef")},
+		},
+	}
+
+	err := s.Set(ctx, "a", session.Data{"test": "1"}, session.StoreOption{UserID: "user1"})
+	assert.NoError(t, err)
+
+	keys, err := s.UserSessions(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, keys)
+}
+
+func TestEncryptedAllowPlaintext(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	// session saved before enable encryption
+	m := new(Memory)
+	data := session.Data{"test": "123"}
+	m.Set(ctx, "a", data, session.StoreOption{})
+
+	s := &Encrypted{
+		Store: m,
+		Keys: []EncryptionKey{
+			{ID: "1", Key: []byte("0123456789abcdef")},
+		},
+	}
+	_, err := s.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err, "expected plaintext not allowed by default")
+
+	s.AllowPlaintext = true
+	b, err := s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	// encrypt on next save
+	err = s.Set(ctx, "a", b, session.StoreOption{})
+	assert.NoError(t, err)
+	raw, _ := m.Get(ctx, "a")
+	assert.Nil(t, raw["test"])
+	assert.NotNil(t, raw[encryptedKey])
+
+	b, err = s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+}
@@ -0,0 +1,15 @@
+package store
+
+// DecodeError is the error when stored session data can not be decoded,
+// retry does not help
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return "store: decode session data: " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moonrhythm/session"
+)
+
+// File stores session data in files under directory, one file per session
+//
+// File name is the hex of sha256 of session key,
+// file content is expires at (unix nanoseconds, 0 is no expire) then encoded data
+type File struct {
+	Dir   string
+	Coder session.StoreCoder
+
+	// OnError is called when gc fails, optional
+	OnError func(err error)
+
+	m sync.RWMutex
+	w worker
+}
+
+// fileTempPrefix is the prefix of temp files, hex file names never start with it
+const fileTempPrefix = ".tmp-"
+
+func (s *File) coder() session.StoreCoder {
+	if s.Coder == nil {
+		return session.DefaultStoreCoder
+	}
+	return s.Coder
+}
+
+func (s *File) path(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(s.Dir, hex.EncodeToString(h[:]))
+}
+
+// GCEvery starts gc every given duration until Close
+func (s *File) GCEvery(d time.Duration) *File {
+	return s.GCEveryContext(context.Background(), d)
+}
+
+// GCEveryContext starts gc every given duration until ctx is done or Close
+func (s *File) GCEveryContext(ctx context.Context, d time.Duration) *File {
+	s.w.every(ctx, d, func() {
+		_, err := s.GC()
+		handleError(s.OnError, err)
+	})
+	return s
+}
+
+// Close stops gc and waits for running gc
+func (s *File) Close() error {
+	s.w.close()
+	return nil
+}
+
+// GC removes expired session files, returns number of removed files
+func (s *File) GC() (int, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	var n int
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), fileTempPrefix) {
+			continue
+		}
+
+		removed, err := s.removeExpired(filepath.Join(s.Dir, e.Name()), now)
+		if err != nil {
+			return n, err
+		}
+		if removed {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// removeExpired removes file if expired,
+// reads only expires at to not decode session data
+func (s *File) removeExpired(name string, now time.Time) (bool, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var exp int64
+	err = binary.Read(f, binary.BigEndian, &exp)
+	f.Close()
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return false, err
+	}
+
+	// remove invalid file
+	if err == nil && (exp == 0 || now.UnixNano() < exp) {
+		return false, nil
+	}
+	err = os.Remove(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get gets session data from file
+func (s *File) Get(_ context.Context, key string) (session.Data, error) {
+	s.m.RLock()
+	b, err := os.ReadFile(s.path(key))
+	s.m.RUnlock()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, session.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 8 {
+		return nil, session.ErrNotFound
+	}
+
+	exp := int64(binary.BigEndian.Uint64(b))
+	if exp > 0 && time.Now().UnixNano() >= exp {
+		return nil, session.ErrNotFound
+	}
+
+	var sessData session.Data
+	err = s.coder().NewDecoder(bytes.NewReader(b[8:])).Decode(&sessData)
+	if err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	return sessData, nil
+}
+
+// Set sets session data to file,
+// writes to temp file then renames to replace old file atomically
+func (s *File) Set(_ context.Context, key string, value session.Data, opt session.StoreOption) error {
+	var buf bytes.Buffer
+	var exp int64
+	if opt.TTL > 0 {
+		exp = time.Now().Add(opt.TTL).UnixNano()
+	}
+	binary.Write(&buf, binary.BigEndian, exp)
+	err := s.coder().NewEncoder(&buf).Encode(value)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(s.Dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(s.Dir, fileTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(buf.Bytes())
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	return os.Rename(f.Name(), s.path(key))
+}
+
+// Del deletes session file
+func (s *File) Del(_ context.Context, key string) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	err := os.Remove(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/moonrhythm/session"
+)
+
+func TestFile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &File{Dir: t.TempDir()}
+
+	opt := session.StoreOption{TTL: 20 * time.Millisecond}
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	_, err := s.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err)
+
+	err = s.Set(ctx, "a", data, opt)
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	time.Sleep(50 * time.Millisecond)
+	b, err = s.Get(ctx, "a")
+	assert.Nil(t, b, "expected expired key return nil")
+	assert.Equal(t, session.ErrNotFound, err)
+
+	s.Set(ctx, "a", data, session.StoreOption{})
+	b, err = s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	s.Del(ctx, "a")
+	_, err = s.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err)
+	assert.NoError(t, s.Del(ctx, "a"), "expected delete not exists key not error")
+}
+
+func TestFileFileName(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &File{Dir: t.TempDir()}
+
+	err := s.Set(ctx, "../../a/b\x00c", session.Data{"test": "123"}, session.StoreOption{})
+	assert.NoError(t, err)
+
+	entries, _ := os.ReadDir(s.Dir)
+	if assert.Len(t, entries, 1) {
+		assert.Len(t, entries[0].Name(), 64, "expected hex of sha256")
+	}
+}
+
+func TestFileGC(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &File{Dir: t.TempDir()}
+	_, err := (&File{Dir: s.Dir + "/notexists"}).GC()
+	assert.NoError(t, err)
+
+	data := session.Data{"test": "123"}
+	s.Set(ctx, "a", data, session.StoreOption{TTL: time.Millisecond})
+	s.Set(ctx, "b", data, session.StoreOption{TTL: time.Minute})
+	s.Set(ctx, "c", data, session.StoreOption{})
+
+	time.Sleep(5 * time.Millisecond)
+	n, err := s.GC()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	entries, _ := os.ReadDir(s.Dir)
+	assert.Len(t, entries, 2)
+
+	_, err = s.Get(ctx, "b")
+	assert.NoError(t, err)
+	_, err = s.Get(ctx, "c")
+	assert.NoError(t, err)
+}
+
+func TestFileConcurrent(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &File{Dir: t.TempDir()}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				data := session.Data{"i": i, "j": j}
+				assert.NoError(t, s.Set(ctx, "a", data, session.StoreOption{}))
+				b, err := s.Get(ctx, "a")
+				if assert.NoError(t, err) {
+					assert.Len(t, b, 2)
+				}
+				assert.NoError(t, s.Set(ctx, fmt.Sprintf("k%d", i), data, session.StoreOption{TTL: time.Millisecond}))
+				_, err = s.GC()
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, _ := os.ReadDir(s.Dir)
+	for _, e := range entries {
+		assert.NotContains(t, e.Name(), fileTempPrefix, "expected no temp file left")
+	}
+}
@@ -2,24 +2,103 @@ package store
 
 import (
 	"bytes"
+	"container/heap"
+	"container/list"
 	"context"
+	"hash/fnv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/moonrhythm/session"
 )
 
+// memoryShards is the number of shards, keys are distributed by hash
+const memoryShards = 32
+
 // Memory stores session data in memory
+//
+// Sessions are distributed into shards that have their own lock,
+// and keeps expiring sessions in heap so gc visits only expired sessions.
+// When total sessions exceed limits, least recently used sessions are evicted
+// from the written shard first while it is locked, so the written session is kept,
+// then from other shards.
+// Concurrent writes may exceed limits until their evictions finished
 type Memory struct {
 	Coder session.StoreCoder
 
-	m sync.RWMutex
-	l map[interface{}]*memoryItem
+	// MaxEntries is the maximum number of sessions, 0 is no limit
+	MaxEntries int
+
+	// MaxBytes is the maximum total size of encoded session data, 0 is no limit
+	MaxBytes int64
+
+	// OnError is called when gc or snapshot worker fails, optional
+	OnError func(err error)
+
+	once   sync.Once
+	shards [memoryShards]memoryShard
+	total  memoryTotal
+	w      worker
+}
+
+// memoryTotal is the total of all shards, updates atomically
+type memoryTotal struct {
+	entries int64
+	bytes   int64
+}
+
+type memoryShard struct {
+	m     sync.Mutex
+	l     map[string]*memoryItem
+	u     map[string]map[string]struct{} // user id => keys
+	lru   list.List                      // front is the most recently used
+	exp   memoryExpHeap
+	bytes int64
+	total *memoryTotal
 }
 
 type memoryItem struct {
-	data []byte
-	exp  time.Time
+	key     string
+	data    []byte
+	exp     time.Time
+	user    string
+	version int64
+
+	elem    *list.Element
+	heapIdx int // index in expiry heap, -1 when item never expires
+}
+
+func (it *memoryItem) expired(now time.Time) bool {
+	return !it.exp.IsZero() && it.exp.Before(now)
+}
+
+// memoryExpHeap is the min-heap of items ordered by expires at
+type memoryExpHeap []*memoryItem
+
+func (h memoryExpHeap) Len() int           { return len(h) }
+func (h memoryExpHeap) Less(i, j int) bool { return h[i].exp.Before(h[j].exp) }
+
+func (h memoryExpHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].heapIdx = i
+	h[j].heapIdx = j
+}
+
+func (h *memoryExpHeap) Push(x interface{}) {
+	it := x.(*memoryItem)
+	it.heapIdx = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *memoryExpHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	it.heapIdx = -1
+	*h = old[:n-1]
+	return it
 }
 
 func (s *Memory) coder() session.StoreCoder {
@@ -29,46 +108,98 @@ func (s *Memory) coder() session.StoreCoder {
 	return s.Coder
 }
 
-func (s *Memory) gcWorker(d time.Duration) {
-	s.GC()
-	time.AfterFunc(d, func() { s.gcWorker(d) })
+func (s *Memory) shard(key string) *memoryShard {
+	return &s.shards[s.shardIndex(key)]
 }
 
-// GCEvery starts gc every given duration
+func (s *Memory) shardIndex(key string) int {
+	s.once.Do(func() {
+		for i := range s.shards {
+			s.shards[i].l = make(map[string]*memoryItem)
+			s.shards[i].u = make(map[string]map[string]struct{})
+			s.shards[i].total = &s.total
+		}
+	})
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % memoryShards)
+}
+
+// overLimits reports whether total sessions exceed limits
+func (s *Memory) overLimits() bool {
+	return (s.MaxEntries > 0 && atomic.LoadInt64(&s.total.entries) > int64(s.MaxEntries)) ||
+		(s.MaxBytes > 0 && atomic.LoadInt64(&s.total.bytes) > s.MaxBytes)
+}
+
+// evict deletes least recently used sessions of other shards than i until total is within limits,
+// shard i was evicted while written, must not hold any lock
+func (s *Memory) evict(i int) {
+	for j := 1; j < memoryShards && s.overLimits(); j++ {
+		sh := &s.shards[(i+j)%memoryShards]
+		sh.m.Lock()
+		sh.evict(0, s.overLimits)
+		sh.m.Unlock()
+	}
+}
+
+// GCEvery starts gc every given duration until Close
 func (s *Memory) GCEvery(d time.Duration) *Memory {
-	time.AfterFunc(d, func() { s.gcWorker(d) })
+	return s.GCEveryContext(context.Background(), d)
+}
+
+// GCEveryContext starts gc every given duration until ctx is done or Close
+func (s *Memory) GCEveryContext(ctx context.Context, d time.Duration) *Memory {
+	s.w.every(ctx, d, func() {
+		_, err := s.GC()
+		handleError(s.OnError, err)
+	})
 	return s
 }
 
-// GC runs gc
-func (s *Memory) GC() {
-	s.m.Lock()
-	defer s.m.Unlock()
+// Close stops background workers
+func (s *Memory) Close() error {
+	s.w.close()
+	return nil
+}
+
+// GC deletes expired sessions, returns number of deleted sessions,
+// error is always nil
+func (s *Memory) GC() (int, error) {
+	s.shard("") // initialize shards
 
 	now := time.Now()
-	for k, v := range s.l {
-		if !v.exp.IsZero() && v.exp.Before(now) {
-			delete(s.l, k)
+	var n int
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.m.Lock()
+		for len(sh.exp) > 0 && sh.exp[0].expired(now) {
+			sh.del(sh.exp[0].key)
+			n++
 		}
+		sh.m.Unlock()
 	}
+	return n, nil
 }
 
 // Get gets session data from memory
 func (s *Memory) Get(_ context.Context, key string) (session.Data, error) {
-	s.m.RLock()
-	defer s.m.RUnlock()
+	sh := s.shard(key)
+	sh.m.Lock()
+	v := sh.get(key)
+	if v != nil {
+		sh.lru.MoveToFront(v.elem)
+	}
+	sh.m.Unlock()
 
-	v := s.l[key]
 	if v == nil {
 		return nil, session.ErrNotFound
 	}
-	if !v.exp.IsZero() && v.exp.Before(time.Now()) {
-		return nil, session.ErrNotFound
-	}
+	// data never modifies after set, decode outside of lock
 	var sessData session.Data
 	err := s.coder().NewDecoder(bytes.NewReader(v.data)).Decode(&sessData)
 	if err != nil {
-		return nil, err
+		return nil, &DecodeError{Err: err}
 	}
 	return sessData, nil
 }
@@ -81,23 +212,176 @@ func (s *Memory) Set(_ context.Context, key string, value session.Data, opt sess
 		return err
 	}
 
-	s.m.Lock()
-	it := &memoryItem{data: buf.Bytes()}
-	if opt.TTL > 0 {
-		it.exp = time.Now().Add(opt.TTL)
+	i := s.shardIndex(key)
+	sh := &s.shards[i]
+	sh.m.Lock()
+	sh.set(key, buf.Bytes(), value.Version(), opt)
+	sh.evict(1, s.overLimits)
+	sh.m.Unlock()
+
+	s.evict(i)
+	return nil
+}
+
+// SetIfVersion sets session data to memory only if stored session version equals to version
+func (s *Memory) SetIfVersion(_ context.Context, key string, value session.Data, version int64, opt session.StoreOption) error {
+	var buf bytes.Buffer
+	err := s.coder().NewEncoder(&buf).Encode(value)
+	if err != nil {
+		return err
+	}
+
+	i := s.shardIndex(key)
+	sh := &s.shards[i]
+	sh.m.Lock()
+	var current int64
+	if v := sh.get(key); v != nil {
+		current = v.version
 	}
-	if s.l == nil {
-		s.l = make(map[interface{}]*memoryItem)
+	if current != version {
+		sh.m.Unlock()
+		return session.ErrConflict
 	}
-	s.l[key] = it
-	s.m.Unlock()
+	sh.set(key, buf.Bytes(), value.Version(), opt)
+	sh.evict(1, s.overLimits)
+	sh.m.Unlock()
+
+	s.evict(i)
+	return nil
+}
+
+// Patch sets and deletes keys in session data
+func (s *Memory) Patch(_ context.Context, key string, set session.Data, del []string, opt session.StoreOption) error {
+	i := s.shardIndex(key)
+	err := s.patch(&s.shards[i], key, set, del, opt)
+	if err != nil {
+		return err
+	}
+	s.evict(i)
+	return nil
+}
+
+func (s *Memory) patch(sh *memoryShard, key string, set session.Data, del []string, opt session.StoreOption) error {
+	sh.m.Lock()
+	defer sh.m.Unlock()
+
+	v := sh.get(key)
+	if v == nil {
+		return session.ErrNotFound
+	}
+
+	var sessData session.Data
+	err := s.coder().NewDecoder(bytes.NewReader(v.data)).Decode(&sessData)
+	if err != nil {
+		return &DecodeError{Err: err}
+	}
+	sessData = applyPatch(sessData, set, del)
+
+	var buf bytes.Buffer
+	err = s.coder().NewEncoder(&buf).Encode(sessData)
+	if err != nil {
+		return err
+	}
+	sh.set(key, buf.Bytes(), sessData.Version(), opt)
+	sh.evict(1, s.overLimits)
 	return nil
 }
 
 // Del deletes session data from memory
 func (s *Memory) Del(_ context.Context, key string) error {
-	s.m.Lock()
-	delete(s.l, key)
-	s.m.Unlock()
+	sh := s.shard(key)
+	sh.m.Lock()
+	sh.del(key)
+	sh.m.Unlock()
 	return nil
 }
+
+// UserSessions returns keys of sessions that associated with user id
+func (s *Memory) UserSessions(_ context.Context, userID string) ([]string, error) {
+	s.shard("") // initialize shards
+
+	now := time.Now()
+	var r []string
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.m.Lock()
+		for k := range sh.u[userID] {
+			if v := sh.l[k]; v != nil && !v.expired(now) {
+				r = append(r, k)
+			}
+		}
+		sh.m.Unlock()
+	}
+	return r, nil
+}
+
+// get returns not expired item, must hold lock
+func (sh *memoryShard) get(key string) *memoryItem {
+	v := sh.l[key]
+	if v == nil || v.expired(time.Now()) {
+		return nil
+	}
+	return v
+}
+
+// set sets encoded data and its indexes, must hold lock
+func (sh *memoryShard) set(key string, data []byte, version int64, opt session.StoreOption) {
+	it := &memoryItem{key: key, data: data, user: opt.UserID, version: version}
+	if opt.TTL > 0 {
+		it.exp = time.Now().Add(opt.TTL)
+	}
+	sh.put(it)
+}
+
+// put puts item and its indexes, must hold lock
+func (sh *memoryShard) put(it *memoryItem) {
+	key := it.key
+	sh.del(key)
+
+	it.heapIdx = -1
+	sh.l[key] = it
+	it.elem = sh.lru.PushFront(it)
+	sh.bytes += int64(len(it.data))
+	atomic.AddInt64(&sh.total.entries, 1)
+	atomic.AddInt64(&sh.total.bytes, int64(len(it.data)))
+	if !it.exp.IsZero() {
+		heap.Push(&sh.exp, it)
+	}
+	if it.user != "" {
+		if sh.u[it.user] == nil {
+			sh.u[it.user] = make(map[string]struct{})
+		}
+		sh.u[it.user][key] = struct{}{}
+	}
+}
+
+// del deletes key and its indexes, must hold lock
+func (sh *memoryShard) del(key string) {
+	it := sh.l[key]
+	if it == nil {
+		return
+	}
+	delete(sh.l, key)
+	sh.lru.Remove(it.elem)
+	sh.bytes -= int64(len(it.data))
+	atomic.AddInt64(&sh.total.entries, -1)
+	atomic.AddInt64(&sh.total.bytes, -int64(len(it.data)))
+	if it.heapIdx >= 0 {
+		heap.Remove(&sh.exp, it.heapIdx)
+	}
+
+	if it.user != "" {
+		delete(sh.u[it.user], key)
+		if len(sh.u[it.user]) == 0 {
+			delete(sh.u, it.user)
+		}
+	}
+}
+
+// evict deletes least recently used sessions while over returns true,
+// keeps given number of the most recently used sessions, must hold lock
+func (sh *memoryShard) evict(keep int, over func() bool) {
+	for sh.lru.Len() > keep && over() {
+		sh.del(sh.lru.Back().Value.(*memoryItem).key)
+	}
+}
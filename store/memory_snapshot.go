@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// memorySnapshotItem is the snapshot format of a session,
+// data is kept as encoded by Coder
+type memorySnapshotItem struct {
+	Key     string
+	Data    []byte
+	Exp     time.Time
+	User    string
+	Version int64
+}
+
+// Snapshot writes all not expired sessions to w,
+// each shard is locked only while copying its sessions
+func (s *Memory) Snapshot(w io.Writer) error {
+	s.shard("") // initialize shards
+
+	enc := gob.NewEncoder(w)
+	now := time.Now()
+	for i := range s.shards {
+		sh := &s.shards[i]
+
+		// from least recently used, restore keeps lru order
+		sh.m.Lock()
+		items := make([]memorySnapshotItem, 0, len(sh.l))
+		for e := sh.lru.Back(); e != nil; e = e.Prev() {
+			it := e.Value.(*memoryItem)
+			if it.expired(now) {
+				continue
+			}
+			items = append(items, memorySnapshotItem{
+				Key:     it.key,
+				Data:    it.data,
+				Exp:     it.exp,
+				User:    it.user,
+				Version: it.version,
+			})
+		}
+		sh.m.Unlock()
+
+		for _, it := range items {
+			err := enc.Encode(&it)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Restore reads sessions from snapshot, expired sessions are dropped,
+// restored sessions replace existing sessions that have the same key
+func (s *Memory) Restore(r io.Reader) error {
+	dec := gob.NewDecoder(r)
+	for {
+		var x memorySnapshotItem
+		err := dec.Decode(&x)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		it := &memoryItem{key: x.Key, data: x.Data, exp: x.Exp, user: x.User, version: x.Version}
+		if it.expired(time.Now()) {
+			continue
+		}
+
+		i := s.shardIndex(it.key)
+		sh := &s.shards[i]
+		sh.m.Lock()
+		sh.put(it)
+		sh.evict(1, s.overLimits)
+		sh.m.Unlock()
+		s.evict(i)
+	}
+}
+
+// SnapshotFile writes snapshot to file,
+// writes to temp file then renames to replace old snapshot atomically
+func (s *Memory) SnapshotFile(name string) error {
+	f, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+fileTempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	err = s.Snapshot(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// RestoreFile restores snapshot from file, not exists file is ignored
+func (s *Memory) RestoreFile(name string) error {
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return s.Restore(f)
+}
+
+// SnapshotEvery writes snapshot to file every given duration until Close,
+// call SnapshotFile on shutdown to not lose sessions that saved after the last snapshot
+func (s *Memory) SnapshotEvery(name string, d time.Duration) *Memory {
+	return s.SnapshotEveryContext(context.Background(), name, d)
+}
+
+// SnapshotEveryContext writes snapshot to file every given duration until ctx is done or Close
+func (s *Memory) SnapshotEveryContext(ctx context.Context, name string, d time.Duration) *Memory {
+	s.w.every(ctx, d, func() {
+		handleError(s.OnError, s.SnapshotFile(name))
+	})
+	return s
+}
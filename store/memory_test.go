@@ -1,7 +1,13 @@
 package store
 
 import (
+	"bytes"
 	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -16,6 +22,7 @@ func TestMemory(t *testing.T) {
 	ctx := context.Background()
 
 	s := new(Memory).GCEvery(10 * time.Millisecond)
+	defer s.Close()
 
 	opt := session.StoreOption{TTL: time.Millisecond}
 
@@ -51,6 +58,7 @@ func TestMemoryWithoutTTL(t *testing.T) {
 	ctx := context.Background()
 
 	s := new(Memory).GCEvery(10 * time.Millisecond)
+	defer s.Close()
 
 	opt := session.StoreOption{}
 
@@ -64,3 +72,401 @@ func TestMemoryWithoutTTL(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, data, b)
 }
+
+func TestMemoryUserSessions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := new(Memory)
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	s.Set(ctx, "a", data, session.StoreOption{UserID: "user1"})
+	s.Set(ctx, "b", data, session.StoreOption{UserID: "user1"})
+	s.Set(ctx, "c", data, session.StoreOption{UserID: "user2"})
+	s.Set(ctx, "d", data, session.StoreOption{UserID: "user1", TTL: time.Millisecond})
+
+	time.Sleep(5 * time.Millisecond)
+	keys, err := s.UserSessions(ctx, "user1")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, keys)
+
+	s.Set(ctx, "b", data, session.StoreOption{UserID: "user2"})
+	s.Del(ctx, "a")
+	keys, err = s.UserSessions(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	keys, err = s.UserSessions(ctx, "user2")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"b", "c"}, keys)
+}
+
+func TestMemorySetIfVersion(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := new(Memory)
+
+	data := session.Data{"test": "123", "_session/version": int64(1)}
+
+	err := s.SetIfVersion(ctx, "a", data, 1, session.StoreOption{})
+	assert.Equal(t, session.ErrConflict, err, "expected conflict when session not exists")
+
+	err = s.SetIfVersion(ctx, "a", data, 0, session.StoreOption{})
+	assert.NoError(t, err)
+
+	err = s.SetIfVersion(ctx, "a", data, 0, session.StoreOption{})
+	assert.Equal(t, session.ErrConflict, err)
+
+	data = session.Data{"test": "456", "_session/version": int64(2)}
+	err = s.SetIfVersion(ctx, "a", data, 1, session.StoreOption{})
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+	assert.EqualValues(t, 2, b.Version())
+
+	s.Set(ctx, "b", data, session.StoreOption{TTL: time.Millisecond})
+	time.Sleep(5 * time.Millisecond)
+	err = s.SetIfVersion(ctx, "b", data, 0, session.StoreOption{})
+	assert.NoError(t, err, "expected expired session treat as not exists")
+}
+
+func TestMemoryPatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := new(Memory)
+
+	err := s.Patch(ctx, "a", session.Data{"a": 1}, nil, session.StoreOption{})
+	assert.Equal(t, session.ErrNotFound, err)
+
+	s.Set(ctx, "a", session.Data{"a": 1, "b": 2, "c": 3}, session.StoreOption{})
+
+	err = s.Patch(ctx, "a", session.Data{"a": 10}, nil, session.StoreOption{})
+	assert.NoError(t, err)
+	err = s.Patch(ctx, "a", session.Data{"d": 4}, []string{"b"}, session.StoreOption{})
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, session.Data{"a": 10, "c": 3, "d": 4}, b)
+}
+
+func TestMemoryMaxEntries(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Memory{MaxEntries: 2}
+
+	// find keys in the same shard
+	var keys []string
+	for i := 0; len(keys) < 3; i++ {
+		k := strconv.Itoa(i)
+		if s.shard(k) == s.shard("0") {
+			keys = append(keys, k)
+		}
+	}
+
+	data := session.Data{"test": "123"}
+	s.Set(ctx, keys[0], data, session.StoreOption{})
+	s.Set(ctx, keys[1], data, session.StoreOption{})
+	s.Get(ctx, keys[0])
+	s.Set(ctx, keys[2], data, session.StoreOption{})
+
+	_, err := s.Get(ctx, keys[0])
+	assert.NoError(t, err)
+	_, err = s.Get(ctx, keys[1])
+	assert.Equal(t, session.ErrNotFound, err, "expected least recently used session evicted")
+	_, err = s.Get(ctx, keys[2])
+	assert.NoError(t, err)
+}
+
+func TestMemoryMaxBytes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Memory{MaxBytes: memoryShards * 1024}
+	data := session.Data{"test": strings.Repeat("a", 100)}
+	for i := 0; i < 10000; i++ {
+		s.Set(ctx, strconv.Itoa(i), data, session.StoreOption{})
+	}
+
+	var n int64
+	for i := range s.shards {
+		n += s.shards[i].bytes
+	}
+	assert.LessOrEqual(t, n, s.MaxBytes)
+	assert.Equal(t, n, s.total.bytes)
+
+	_, err := s.Get(ctx, "9999")
+	assert.NoError(t, err, "expected latest session kept")
+}
+
+func TestMemoryLimitsGlobal(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	data := session.Data{"test": "123"}
+
+	t.Run("Bound", func(t *testing.T) {
+		s := &Memory{MaxEntries: 10}
+		for i := 0; i < 1000; i++ {
+			s.Set(ctx, strconv.Itoa(i), data, session.StoreOption{})
+		}
+
+		var n int
+		for i := range s.shards {
+			n += len(s.shards[i].l)
+		}
+		assert.Equal(t, 10, n)
+		assert.EqualValues(t, 10, s.total.entries)
+
+		_, err := s.Get(ctx, "999")
+		assert.NoError(t, err, "expected latest session kept")
+	})
+
+	t.Run("HotShard", func(t *testing.T) {
+		s := &Memory{MaxEntries: 100}
+
+		// keys in the same shard are kept while total is within limits
+		var keys []string
+		for i := 0; len(keys) < 20; i++ {
+			k := strconv.Itoa(i)
+			if s.shard(k) == s.shard("0") {
+				keys = append(keys, k)
+			}
+		}
+		for _, k := range keys {
+			s.Set(ctx, k, data, session.StoreOption{})
+		}
+		for _, k := range keys {
+			_, err := s.Get(ctx, k)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		s := &Memory{MaxEntries: 50}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					s.Set(ctx, strconv.Itoa(i*1000+j), data, session.StoreOption{})
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		assert.LessOrEqual(t, s.total.entries, int64(50))
+	})
+}
+
+func TestMemoryGC(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := new(Memory)
+	data := session.Data{"test": "123"}
+	for i := 0; i < 100; i++ {
+		ttl := time.Hour
+		if i%2 == 0 {
+			ttl = time.Millisecond
+		}
+		s.Set(ctx, strconv.Itoa(i), data, session.StoreOption{TTL: ttl})
+	}
+	s.Set(ctx, "persist", data, session.StoreOption{})
+	s.Set(ctx, "0", data, session.StoreOption{TTL: time.Hour}) // replace expiring session
+
+	time.Sleep(5 * time.Millisecond)
+	s.GC()
+
+	var n, h int
+	for i := range s.shards {
+		n += len(s.shards[i].l)
+		h += len(s.shards[i].exp)
+	}
+	assert.Equal(t, 52, n)
+	assert.Equal(t, 51, h)
+
+	_, err := s.Get(ctx, "0")
+	assert.NoError(t, err)
+	_, err = s.Get(ctx, "persist")
+	assert.NoError(t, err)
+}
+
+func TestMemoryGCEveryContext(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	data := session.Data{"test": "123"}
+
+	gcCtx, cancel := context.WithCancel(ctx)
+	s := new(Memory).GCEveryContext(gcCtx, 10*time.Millisecond)
+	defer s.Close()
+
+	s.Set(ctx, "a", data, session.StoreOption{TTL: time.Millisecond})
+	time.Sleep(30 * time.Millisecond)
+	n, _ := s.GC()
+	assert.Equal(t, 0, n, "expected gc ran")
+
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	s.Set(ctx, "a", data, session.StoreOption{TTL: time.Millisecond})
+	time.Sleep(30 * time.Millisecond)
+	n, _ = s.GC()
+	assert.Equal(t, 1, n, "expected gc stopped")
+}
+
+func TestMemoryGCEveryInvalid(t *testing.T) {
+	t.Parallel()
+
+	s := new(Memory)
+	assert.PanicsWithValue(t, "store: non-positive interval 0s", func() { s.GCEvery(0) })
+	assert.NoError(t, s.Close())
+}
+
+func TestMemoryClose(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	data := session.Data{"test": "123"}
+
+	s := new(Memory).GCEvery(10 * time.Millisecond)
+	assert.NoError(t, s.Close())
+
+	s.Set(ctx, "a", data, session.StoreOption{TTL: time.Millisecond})
+	time.Sleep(30 * time.Millisecond)
+	n, err := s.GC()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n, "expected gc stopped")
+}
+
+func TestMemorySnapshot(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := new(Memory)
+	s.Set(ctx, "a", session.Data{"a": "1"}, session.StoreOption{TTL: time.Hour, UserID: "u"})
+	s.Set(ctx, "b", session.Data{"b": "2"}, session.StoreOption{})
+	s.Set(ctx, "c", session.Data{"c": "3"}, session.StoreOption{TTL: time.Millisecond})
+
+	var buf bytes.Buffer
+	err := s.Snapshot(&buf)
+	assert.NoError(t, err)
+	snapshot := buf.Bytes()
+
+	r := new(Memory)
+	err = r.Restore(bytes.NewReader(snapshot))
+	assert.NoError(t, err)
+
+	b, err := r.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, session.Data{"a": "1"}, b)
+	b, err = r.Get(ctx, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, session.Data{"b": "2"}, b)
+	keys, _ := r.UserSessions(ctx, "u")
+	assert.Equal(t, []string{"a"}, keys)
+
+	// expired after snapshot
+	s.Set(ctx, "d", session.Data{"d": "4"}, session.StoreOption{TTL: 10 * time.Millisecond})
+	buf.Reset()
+	s.Snapshot(&buf)
+	time.Sleep(20 * time.Millisecond)
+
+	r = new(Memory)
+	err = r.Restore(&buf)
+	assert.NoError(t, err)
+	_, err = r.Get(ctx, "c")
+	assert.Equal(t, session.ErrNotFound, err)
+	_, err = r.Get(ctx, "d")
+	assert.Equal(t, session.ErrNotFound, err)
+	var n int
+	for i := range r.shards {
+		n += len(r.shards[i].l)
+	}
+	assert.Equal(t, 2, n, "expected expired sessions dropped")
+}
+
+func TestMemorySnapshotFile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	name := filepath.Join(t.TempDir(), "session.snapshot")
+
+	s := new(Memory)
+	err := s.RestoreFile(name)
+	assert.NoError(t, err, "expected not exists snapshot ignored")
+
+	s.Set(ctx, "a", session.Data{"a": "1"}, session.StoreOption{TTL: time.Hour})
+	err = s.SnapshotFile(name)
+	assert.NoError(t, err)
+
+	r := new(Memory)
+	err = r.RestoreFile(name)
+	assert.NoError(t, err)
+	b, err := r.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, session.Data{"a": "1"}, b)
+
+	entries, _ := os.ReadDir(filepath.Dir(name))
+	assert.Len(t, entries, 1, "expected temp file removed")
+}
+
+func BenchmarkMemoryGetSetParallel(b *testing.B) {
+	ctx := context.Background()
+	s := new(Memory)
+	data := session.Data{"test": "123"}
+	opt := session.StoreOption{TTL: time.Minute}
+	for i := 0; i < 1000; i++ {
+		s.Set(ctx, strconv.Itoa(i), data, opt)
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			k := strconv.Itoa(i % 1000)
+			if i%4 == 0 {
+				s.Set(ctx, k, data, opt)
+			} else {
+				s.Get(ctx, k)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkMemoryGC(b *testing.B) {
+	ctx := context.Background()
+	s := new(Memory)
+	data := session.Data{"test": "123"}
+	for i := 0; i < 100000; i++ {
+		s.Set(ctx, strconv.Itoa(i), data, session.StoreOption{TTL: time.Hour})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for j := 0; j < 10; j++ {
+			s.Set(ctx, "expired"+strconv.Itoa(j), data, session.StoreOption{TTL: time.Nanosecond})
+		}
+		b.StartTimer()
+		s.GC()
+	}
+}
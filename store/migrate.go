@@ -0,0 +1,221 @@
+package store
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/moonrhythm/session"
+)
+
+// MigrateMode is the write mode of Migrate
+type MigrateMode int32
+
+// Migrate modes
+const (
+	// MigrateDualWrite writes to both new and old stores
+	MigrateDualWrite MigrateMode = iota
+
+	// MigrateWriteNew writes to new store only, uses after all app instances read from new store
+	MigrateWriteNew
+)
+
+// Migrate moves sessions from Old store to New store without logging users out
+//
+// Get reads New store first then Old store, session found in Old store
+// is copied to New store with TTL if New store does not have it. Del deletes from both stores.
+//
+// Start with MigrateDualWrite, so instances that still use Old store see new sessions,
+// switch to MigrateWriteNew when rollout finished, and replace Migrate with New store
+// after sessions in Old store expired
+type Migrate struct {
+	Old session.Store
+	New session.Store
+
+	// TTL is the ttl of session that copied from Old store,
+	// should be the same as session max age, zero copies sessions without expiration
+	TTL time.Duration
+
+	// OnError is called when copy session from Old store or delete from Old store fails,
+	// session is still read from Old store, optional
+	OnError func(err error)
+
+	mode int32
+}
+
+// Mode returns current write mode
+func (s *Migrate) Mode() MigrateMode {
+	return MigrateMode(atomic.LoadInt32(&s.mode))
+}
+
+// SetMode changes write mode, safe to call while serving
+func (s *Migrate) SetMode(mode MigrateMode) {
+	atomic.StoreInt32(&s.mode, int32(mode))
+}
+
+// Get gets session data from New store, fallback to Old store
+func (s *Migrate) Get(ctx context.Context, key string) (session.Data, error) {
+	data, err := s.New.Get(ctx, key)
+	if err != session.ErrNotFound {
+		return data, err
+	}
+
+	data, err = s.Old.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// copy forward, Old store still has the session in dual write mode
+	err = s.copy(ctx, key, data, session.StoreOption{UserID: data.UserID(), TTL: s.TTL})
+	if err == session.ErrConflict {
+		// session was saved to New store after read
+		return s.New.Get(ctx, key)
+	}
+	if err != nil {
+		handleError(s.OnError, err)
+		return data, nil
+	}
+	if s.Mode() == MigrateWriteNew {
+		handleError(s.OnError, s.Old.Del(ctx, key))
+	}
+	return data, nil
+}
+
+// copy sets session data to New store only if New store does not have the session,
+// returns session.ErrConflict if it has.
+// Session is set without check if New store is not session.VersionedStore
+func (s *Migrate) copy(ctx context.Context, key string, value session.Data, opt session.StoreOption) error {
+	vs, ok := s.New.(session.VersionedStore)
+	if !ok {
+		return s.New.Set(ctx, key, value, opt)
+	}
+	return vs.SetIfVersion(ctx, key, value, 0, opt)
+}
+
+// Set sets session data to New store, and Old store in dual write mode
+func (s *Migrate) Set(ctx context.Context, key string, value session.Data, opt session.StoreOption) error {
+	err := s.New.Set(ctx, key, value, opt)
+	if err != nil {
+		return err
+	}
+	if s.Mode() == MigrateWriteNew {
+		return nil
+	}
+	return s.Old.Set(ctx, key, value, opt)
+}
+
+// SetIfVersion sets session data to New store only if version matched, and Old store in dual write mode,
+// returns session.ErrNotSupported if New store is not session.VersionedStore
+//
+// Version is checked in New store, then in Old store if New store does not have the session
+func (s *Migrate) SetIfVersion(ctx context.Context, key string, value session.Data, version int64, opt session.StoreOption) error {
+	vs, ok := s.New.(session.VersionedStore)
+	if !ok {
+		return session.ErrNotSupported
+	}
+
+	err := vs.SetIfVersion(ctx, key, value, version, opt)
+	if err == session.ErrConflict && version != 0 {
+		// session was not copied forward
+		err = s.setIfOldVersion(ctx, key, value, version, opt)
+	}
+	if err != nil {
+		return err
+	}
+	if s.Mode() == MigrateWriteNew {
+		return nil
+	}
+	return s.Old.Set(ctx, key, value, opt)
+}
+
+// setIfOldVersion sets session data to New store if New store does not have the session
+// and session version in Old store matched
+func (s *Migrate) setIfOldVersion(ctx context.Context, key string, value session.Data, version int64, opt session.StoreOption) error {
+	_, err := s.New.Get(ctx, key)
+	if err == nil {
+		return session.ErrConflict
+	}
+	if err != session.ErrNotFound {
+		return err
+	}
+
+	data, err := s.Old.Get(ctx, key)
+	if err == session.ErrNotFound {
+		return session.ErrConflict
+	}
+	if err != nil {
+		return err
+	}
+	if data.Version() != version {
+		return session.ErrConflict
+	}
+	return s.copy(ctx, key, value, opt)
+}
+
+// Patch patches session data in New store, then copies patched session data to Old store in dual write mode,
+// returns session.ErrNotSupported if New store is not session.PatchStore
+func (s *Migrate) Patch(ctx context.Context, key string, set session.Data, del []string, opt session.StoreOption) error {
+	ps, ok := s.New.(session.PatchStore)
+	if !ok {
+		return session.ErrNotSupported
+	}
+
+	err := ps.Patch(ctx, key, set, del, opt)
+	if err != nil {
+		return err
+	}
+	if s.Mode() == MigrateWriteNew {
+		return nil
+	}
+
+	data, err := s.New.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return s.Old.Set(ctx, key, data, opt)
+}
+
+// UserSessions returns keys of sessions that associated with user id from both stores,
+// returns session.ErrNotSupported if New store is not session.UserStore,
+// Old store is skipped if it is not session.UserStore
+func (s *Migrate) UserSessions(ctx context.Context, userID string) ([]string, error) {
+	us, ok := s.New.(session.UserStore)
+	if !ok {
+		return nil, session.ErrNotSupported
+	}
+
+	r, err := us.UserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	us, ok = s.Old.(session.UserStore)
+	if !ok {
+		return r, nil
+	}
+	keys, err := us.UserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(r))
+	for _, k := range r {
+		found[k] = true
+	}
+	for _, k := range keys {
+		if !found[k] {
+			found[k] = true
+			r = append(r, k)
+		}
+	}
+	return r, nil
+}
+
+// Del deletes session data from both stores
+func (s *Migrate) Del(ctx context.Context, key string) error {
+	err := s.New.Del(ctx, key)
+	if err != nil {
+		return err
+	}
+	return s.Old.Del(ctx, key)
+}
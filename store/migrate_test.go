@@ -0,0 +1,271 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/moonrhythm/session"
+)
+
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	oldStore := new(Memory)
+	newStore := new(Memory)
+	s := &Migrate{Old: oldStore, New: newStore, TTL: time.Minute}
+
+	opt := session.StoreOption{TTL: time.Minute}
+
+	// dual write
+	err := s.Set(ctx, "a", session.Data{"test": "a"}, opt)
+	assert.NoError(t, err)
+	_, err = oldStore.Get(ctx, "a")
+	assert.NoError(t, err)
+	_, err = newStore.Get(ctx, "a")
+	assert.NoError(t, err)
+
+	// copy forward
+	oldStore.Set(ctx, "b", session.Data{"test": "b", "_session/user": "user1"}, opt)
+	b, err := s.Get(ctx, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", b["test"])
+	b, err = newStore.Get(ctx, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", b["test"])
+	keys, _ := newStore.UserSessions(ctx, "user1")
+	assert.Equal(t, []string{"b"}, keys)
+	_, err = oldStore.Get(ctx, "b")
+	assert.NoError(t, err, "expected old session kept in dual write mode")
+
+	// new store first
+	newStore.Set(ctx, "b", session.Data{"test": "new"}, opt)
+	b, err = s.Get(ctx, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, "new", b["test"])
+
+	_, err = s.Get(ctx, "c")
+	assert.Equal(t, session.ErrNotFound, err)
+
+	// delete from both
+	err = s.Del(ctx, "b")
+	assert.NoError(t, err)
+	_, err = oldStore.Get(ctx, "b")
+	assert.Equal(t, session.ErrNotFound, err)
+	_, err = newStore.Get(ctx, "b")
+	assert.Equal(t, session.ErrNotFound, err)
+}
+
+func TestMigrateWriteNew(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	oldStore := new(Memory)
+	newStore := new(Memory)
+	s := &Migrate{Old: oldStore, New: newStore, TTL: time.Minute}
+	s.SetMode(MigrateWriteNew)
+	assert.Equal(t, MigrateWriteNew, s.Mode())
+
+	opt := session.StoreOption{TTL: time.Minute}
+
+	err := s.Set(ctx, "a", session.Data{"test": "a"}, opt)
+	assert.NoError(t, err)
+	_, err = oldStore.Get(ctx, "a")
+	assert.Equal(t, session.ErrNotFound, err)
+	_, err = newStore.Get(ctx, "a")
+	assert.NoError(t, err)
+
+	// copy forward drains old store
+	oldStore.Set(ctx, "b", session.Data{"test": "b"}, opt)
+	b, err := s.Get(ctx, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, "b", b["test"])
+	_, err = newStore.Get(ctx, "b")
+	assert.NoError(t, err)
+	_, err = oldStore.Get(ctx, "b")
+	assert.Equal(t, session.ErrNotFound, err)
+}
+
+func TestMigrateTTL(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	oldStore := new(Memory)
+	oldStore.Set(ctx, "a", session.Data{"test": "a"}, session.StoreOption{TTL: time.Minute})
+
+	// zero ttl copies without expiration
+	newStore := new(Memory)
+	s := &Migrate{Old: oldStore, New: newStore}
+	b, err := s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", b["test"])
+	assert.Len(t, newStore.shard("a").exp, 0)
+	_, err = newStore.Get(ctx, "a")
+	assert.NoError(t, err)
+}
+
+// failStore is the memory store that fails on write when error is set
+type failStore struct {
+	*Memory
+	setErr error
+	delErr error
+	onGet  func()
+}
+
+func (s *failStore) Get(ctx context.Context, key string) (session.Data, error) {
+	data, err := s.Memory.Get(ctx, key)
+	if s.onGet != nil {
+		s.onGet()
+	}
+	return data, err
+}
+
+func (s *failStore) Set(ctx context.Context, key string, value session.Data, opt session.StoreOption) error {
+	if s.setErr != nil {
+		return s.setErr
+	}
+	return s.Memory.Set(ctx, key, value, opt)
+}
+
+func (s *failStore) SetIfVersion(ctx context.Context, key string, value session.Data, version int64, opt session.StoreOption) error {
+	if s.setErr != nil {
+		return s.setErr
+	}
+	return s.Memory.SetIfVersion(ctx, key, value, version, opt)
+}
+
+func (s *failStore) Del(ctx context.Context, key string) error {
+	if s.delErr != nil {
+		return s.delErr
+	}
+	return s.Memory.Del(ctx, key)
+}
+
+func TestMigrateOnError(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	var errs []error
+	oldStore := &failStore{Memory: new(Memory), delErr: fmt.Errorf("del error")}
+	oldStore.Set(ctx, "a", session.Data{"test": "a"}, session.StoreOption{TTL: time.Minute})
+
+	// copy failed, returns data from old store
+	s := &Migrate{
+		Old:     oldStore,
+		New:     &failStore{Memory: new(Memory), setErr: fmt.Errorf("set error")},
+		TTL:     time.Minute,
+		OnError: func(err error) { errs = append(errs, err) },
+	}
+	b, err := s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "a", b["test"])
+	assert.Equal(t, []error{fmt.Errorf("set error")}, errs)
+
+	// delete from old store failed
+	errs = nil
+	s.New = new(Memory)
+	s.SetMode(MigrateWriteNew)
+	_, err = s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []error{fmt.Errorf("del error")}, errs)
+}
+
+func TestMigrateForward(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	oldStore := new(Memory)
+	newStore := new(Memory)
+	s := &Migrate{Old: oldStore, New: newStore, TTL: time.Minute}
+
+	opt := session.StoreOption{TTL: time.Minute, UserID: "user1"}
+
+	// version checks in new store, dual write to old store
+	err := s.SetIfVersion(ctx, "a", session.Data{"test": "1", "_session/version": int64(1)}, 0, opt)
+	assert.NoError(t, err)
+	err = s.SetIfVersion(ctx, "a", session.Data{"test": "2", "_session/version": int64(1)}, 0, opt)
+	assert.Equal(t, session.ErrConflict, err)
+	b, _ := oldStore.Get(ctx, "a")
+	assert.Equal(t, "1", b["test"])
+
+	// patched data copies to old store
+	err = s.Patch(ctx, "a", session.Data{"test": "3"}, nil, opt)
+	assert.NoError(t, err)
+	b, _ = oldStore.Get(ctx, "a")
+	assert.Equal(t, "3", b["test"])
+
+	// user sessions from both stores
+	oldStore.Set(ctx, "b", session.Data{}, opt)
+	keys, err := s.UserSessions(ctx, "user1")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, keys)
+
+	s = &Migrate{Old: oldStore, New: &errStore{}}
+	assert.Equal(t, session.ErrNotSupported, s.SetIfVersion(ctx, "a", session.Data{}, 0, opt))
+	assert.Equal(t, session.ErrNotSupported, s.Patch(ctx, "a", session.Data{}, nil, opt))
+	_, err = s.UserSessions(ctx, "user1")
+	assert.Equal(t, session.ErrNotSupported, err)
+}
+
+func TestMigrateCopyConflict(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	newStore := new(Memory)
+	oldStore := &failStore{Memory: new(Memory)}
+	oldStore.Set(ctx, "a", session.Data{"test": "old", "_session/version": int64(1)}, session.StoreOption{})
+
+	// session saved to new store while reading old store
+	oldStore.onGet = func() {
+		newStore.Set(ctx, "a", session.Data{"test": "new", "_session/version": int64(2)}, session.StoreOption{})
+	}
+
+	s := &Migrate{Old: oldStore, New: newStore, TTL: time.Minute}
+	b, err := s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, "new", b["test"])
+
+	b, _ = newStore.Get(ctx, "a")
+	assert.Equal(t, "new", b["test"], "expected copy not overwrite new store")
+}
+
+func TestMigrateSetIfOldVersion(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	oldStore := new(Memory)
+	newStore := new(Memory)
+	s := &Migrate{Old: oldStore, New: newStore, TTL: time.Minute}
+
+	opt := session.StoreOption{TTL: time.Minute}
+
+	// session was not copied forward
+	oldStore.Set(ctx, "a", session.Data{"test": "1", "_session/version": int64(1)}, opt)
+
+	err := s.SetIfVersion(ctx, "a", session.Data{"test": "2", "_session/version": int64(3)}, 2, opt)
+	assert.Equal(t, session.ErrConflict, err, "expected version checked in old store")
+
+	err = s.SetIfVersion(ctx, "a", session.Data{"test": "2", "_session/version": int64(2)}, 1, opt)
+	assert.NoError(t, err)
+	b, _ := newStore.Get(ctx, "a")
+	assert.Equal(t, "2", b["test"])
+	b, _ = oldStore.Get(ctx, "a")
+	assert.Equal(t, "2", b["test"])
+
+	err = s.SetIfVersion(ctx, "a", session.Data{"test": "3", "_session/version": int64(2)}, 1, opt)
+	assert.Equal(t, session.ErrConflict, err)
+
+	err = s.SetIfVersion(ctx, "b", session.Data{"test": "1", "_session/version": int64(2)}, 1, opt)
+	assert.Equal(t, session.ErrConflict, err, "expected conflict when session not exists")
+}
@@ -0,0 +1,19 @@
+package store
+
+import (
+	"github.com/moonrhythm/session"
+)
+
+// applyPatch sets and deletes keys in session data
+func applyPatch(data session.Data, set session.Data, del []string) session.Data {
+	if data == nil {
+		data = make(session.Data)
+	}
+	for k, v := range set {
+		data[k] = v
+	}
+	for _, k := range del {
+		delete(data, k)
+	}
+	return data
+}
@@ -0,0 +1,270 @@
+package store
+
+import (
+	"bytes"
+	"context"
+
+	redigo "github.com/gomodule/redigo/redis"
+
+	"github.com/moonrhythm/session"
+)
+
+// Redigo is the redis store
+// implement by using "github.com/gomodule/redigo/redis" package
+type Redigo struct {
+	Pool   *redigo.Pool
+	Prefix string
+	Coder  session.StoreCoder
+}
+
+func (s *Redigo) coder() session.StoreCoder {
+	if s.Coder == nil {
+		return session.DefaultStoreCoder
+	}
+	return s.Coder
+}
+
+func (s *Redigo) decode(b []byte) (session.Data, error) {
+	var sessData session.Data
+	err := s.coder().NewDecoder(bytes.NewReader(b)).Decode(&sessData)
+	if err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	return sessData, nil
+}
+
+func (s *Redigo) encode(value session.Data) ([]byte, error) {
+	var buf bytes.Buffer
+	err := s.coder().NewEncoder(&buf).Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Get gets session data from redis
+func (s *Redigo) Get(ctx context.Context, key string) (session.Data, error) {
+	c, err := s.Pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	data, err := redigo.Bytes(redigo.DoContext(c, ctx, "GET", s.key(key)))
+	if err == redigo.ErrNil {
+		return nil, session.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(data)
+}
+
+// Set sets session data to redis
+func (s *Redigo) Set(ctx context.Context, key string, value session.Data, opt session.StoreOption) error {
+	b, err := s.encode(value)
+	if err != nil {
+		return err
+	}
+
+	c, err := s.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if opt.UserID == "" {
+		_, err = redigo.DoContext(c, ctx, "SET", s.setArgs(key, b, opt)...)
+		return err
+	}
+
+	c.Send("MULTI")
+	s.send(c, key, b, opt)
+	_, err = redigo.DoContext(c, ctx, "EXEC")
+	return err
+}
+
+// SetIfVersion sets session data to redis only if stored session version equals to version,
+// stored session is watched to detect concurrent modification
+func (s *Redigo) SetIfVersion(ctx context.Context, key string, value session.Data, version int64, opt session.StoreOption) error {
+	b, err := s.encode(value)
+	if err != nil {
+		return err
+	}
+
+	c, err := s.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	_, err = redigo.DoContext(c, ctx, "WATCH", s.key(key))
+	if err != nil {
+		return err
+	}
+
+	var current int64
+	data, err := redigo.Bytes(redigo.DoContext(c, ctx, "GET", s.key(key)))
+	if err == nil {
+		sessData, err := s.decode(data)
+		if err != nil {
+			return err
+		}
+		current = sessData.Version()
+	} else if err != redigo.ErrNil {
+		return err
+	}
+	if current != version {
+		return session.ErrConflict
+	}
+
+	c.Send("MULTI")
+	s.send(c, key, b, opt)
+	r, err := redigo.DoContext(c, ctx, "EXEC")
+	if err != nil {
+		return err
+	}
+	if r == nil {
+		// watched key was modified
+		return session.ErrConflict
+	}
+	return nil
+}
+
+// Patch sets and deletes keys in session data,
+// stored session is watched and patch retries when session was modified concurrently
+func (s *Redigo) Patch(ctx context.Context, key string, set session.Data, del []string, opt session.StoreOption) error {
+	c, err := s.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	for i := 0; i < redisPatchAttempts; i++ {
+		_, err = redigo.DoContext(c, ctx, "WATCH", s.key(key))
+		if err != nil {
+			return err
+		}
+
+		data, err := redigo.Bytes(redigo.DoContext(c, ctx, "GET", s.key(key)))
+		if err == redigo.ErrNil {
+			c.Do("UNWATCH")
+			return session.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		sessData, err := s.decode(data)
+		if err != nil {
+			c.Do("UNWATCH")
+			return err
+		}
+		b, err := s.encode(applyPatch(sessData, set, del))
+		if err != nil {
+			c.Do("UNWATCH")
+			return err
+		}
+
+		c.Send("MULTI")
+		s.send(c, key, b, opt)
+		r, err := redigo.DoContext(c, ctx, "EXEC")
+		if err != nil {
+			return err
+		}
+		if r != nil {
+			return nil
+		}
+	}
+	return session.ErrConflict
+}
+
+func (s *Redigo) setArgs(key string, b []byte, opt session.StoreOption) []interface{} {
+	args := []interface{}{s.key(key), b}
+	if opt.TTL > 0 {
+		args = append(args, "PX", redisMilliseconds(opt.TTL))
+	}
+	return args
+}
+
+// send sends commands to set encoded data and its user index
+func (s *Redigo) send(c redigo.Conn, key string, b []byte, opt session.StoreOption) {
+	c.Send("SET", s.setArgs(key, b, opt)...)
+	if opt.UserID == "" {
+		return
+	}
+
+	// add session key into user's index,
+	// index lives as long as the latest saved session
+	userKey := s.userKey(opt.UserID)
+	c.Send("SADD", userKey, key)
+	if opt.TTL > 0 {
+		c.Send("PEXPIRE", userKey, redisMilliseconds(opt.TTL))
+	} else {
+		c.Send("PERSIST", userKey)
+	}
+}
+
+// Del deletes session data from redis
+func (s *Redigo) Del(ctx context.Context, key string) error {
+	c, err := s.Pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	_, err = redigo.DoContext(c, ctx, "DEL", s.key(key))
+	return err
+}
+
+func (s *Redigo) key(key string) string {
+	return s.Prefix + key
+}
+
+func (s *Redigo) userKey(userID string) string {
+	return redisUserKey(s.Prefix, userID)
+}
+
+// UserSessions returns keys of sessions that associated with user id
+func (s *Redigo) UserSessions(ctx context.Context, userID string) ([]string, error) {
+	c, err := s.Pool.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	userKey := s.userKey(userID)
+	keys, err := redigo.Strings(redigo.DoContext(c, ctx, "SMEMBERS", userKey))
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	for _, k := range keys {
+		c.Send("EXISTS", s.key(k))
+	}
+	c.Flush()
+
+	// remove expired sessions from index
+	var r, expired []string
+	for _, k := range keys {
+		n, err := redigo.Int(c.Receive())
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			r = append(r, k)
+		} else {
+			expired = append(expired, k)
+		}
+	}
+	if len(expired) > 0 {
+		_, err = redigo.DoContext(c, ctx, "SREM", redigo.Args{}.Add(userKey).AddFlat(expired)...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/moonrhythm/session"
+)
+
+func newRedigoPool() *redigo.Pool {
+	return &redigo.Pool{
+		Dial: func() (redigo.Conn, error) {
+			return redigo.Dial("tcp", redisAddr())
+		},
+	}
+}
+
+func TestRedigo(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Redigo{
+		Prefix: "session:",
+		Pool:   newRedigoPool(),
+	}
+
+	opt := session.StoreOption{TTL: time.Second}
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	err := s.Set(ctx, "__redigo", data, opt)
+	assert.NoError(t, err)
+
+	time.Sleep(2 * time.Second)
+	b, err := s.Get(ctx, "__redigo")
+	assert.Nil(t, b, "expected expired key return nil")
+	assert.Error(t, err)
+
+	s.Set(ctx, "__redigo", data, opt)
+	time.Sleep(2 * time.Second)
+	_, err = s.Get(ctx, "__redigo")
+	assert.Error(t, err, "expected expired key return error")
+
+	s.Set(ctx, "__redigo", data, opt)
+	b, err = s.Get(ctx, "__redigo")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	s.Del(ctx, "__redigo")
+	_, err = s.Get(ctx, "__redigo")
+	assert.Error(t, err)
+}
+
+func TestRedigoWithoutTTL(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Redigo{
+		Prefix: "session:",
+		Pool:   newRedigoPool(),
+	}
+
+	opt := session.StoreOption{}
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	err := s.Set(ctx, "__redigo_without_ttl", data, opt)
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "__redigo_without_ttl")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+}
+
+func TestRedigoSubMillisecondTTL(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Redigo{
+		Prefix: "session:",
+		Pool:   newRedigoPool(),
+	}
+
+	opt := session.StoreOption{TTL: 500 * time.Microsecond, UserID: "__redigo_sub_ms_user"}
+	assert.Equal(t, []interface{}{"session:a", []byte{}, "PX", int64(1)}, s.setArgs("a", []byte{}, opt))
+
+	err := s.Set(ctx, "__redigo_sub_ms", session.Data{"test": "123"}, opt)
+	assert.NoError(t, err)
+}
+
+func TestRedigoUserSessions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Redigo{
+		Prefix: "session:",
+		Pool:   newRedigoPool(),
+	}
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	c := s.Pool.Get()
+	c.Do("DEL", s.userKey("__redigo_user1"))
+	c.Close()
+
+	s.Set(ctx, "__redigo_user_a", data, session.StoreOption{UserID: "__redigo_user1", TTL: time.Minute})
+	s.Set(ctx, "__redigo_user_b", data, session.StoreOption{UserID: "__redigo_user1", TTL: time.Minute})
+
+	keys, err := s.UserSessions(ctx, "__redigo_user1")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"__redigo_user_a", "__redigo_user_b"}, keys)
+
+	s.Del(ctx, "__redigo_user_a")
+	keys, err = s.UserSessions(ctx, "__redigo_user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"__redigo_user_b"}, keys)
+
+	c = s.Pool.Get()
+	n, _ := redigo.Int(c.Do("SCARD", s.userKey("__redigo_user1")))
+	c.Close()
+	assert.EqualValues(t, 1, n, "expected deleted session removed from index")
+}
+
+func TestRedigoSetIfVersion(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Redigo{
+		Prefix: "session:",
+		Pool:   newRedigoPool(),
+	}
+
+	opt := session.StoreOption{TTL: time.Minute}
+	data := session.Data{"test": "123", "_session/version": int64(1)}
+
+	s.Del(ctx, "__redigo_version")
+	err := s.SetIfVersion(ctx, "__redigo_version", data, 1, opt)
+	assert.Equal(t, session.ErrConflict, err, "expected conflict when session not exists")
+
+	err = s.SetIfVersion(ctx, "__redigo_version", data, 0, opt)
+	assert.NoError(t, err)
+
+	err = s.SetIfVersion(ctx, "__redigo_version", data, 0, opt)
+	assert.Equal(t, session.ErrConflict, err)
+
+	data = session.Data{"test": "456", "_session/version": int64(2)}
+	err = s.SetIfVersion(ctx, "__redigo_version", data, 1, opt)
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "__redigo_version")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+}
+
+func TestRedigoPatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Redigo{
+		Prefix: "session:",
+		Pool:   newRedigoPool(),
+	}
+
+	opt := session.StoreOption{TTL: time.Minute}
+
+	s.Del(ctx, "__redigo_patch")
+	err := s.Patch(ctx, "__redigo_patch", session.Data{"a": 1}, nil, opt)
+	assert.Equal(t, session.ErrNotFound, err)
+
+	s.Set(ctx, "__redigo_patch", session.Data{"a": 1, "b": 2, "c": 3}, opt)
+
+	err = s.Patch(ctx, "__redigo_patch", session.Data{"a": 10}, nil, opt)
+	assert.NoError(t, err)
+	err = s.Patch(ctx, "__redigo_patch", session.Data{"d": 4}, []string{"b"}, opt)
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "__redigo_patch")
+	assert.NoError(t, err)
+	assert.Equal(t, session.Data{"a": 10, "c": 3, "d": 4}, b)
+}
+
+func TestRedigoHashTag(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Redigo{
+		Prefix: "session:",
+		Pool:   newRedigoPool(),
+	}
+
+	data := session.Data{"test": "123"}
+	opt := session.StoreOption{UserID: "__redigo_tag_user", TTL: time.Minute}
+
+	err := s.Set(ctx, "__redigo_tag", data, opt)
+	assert.NoError(t, err)
+
+	c := s.Pool.Get()
+	defer c.Close()
+	n, _ := redigo.Int(c.Do("EXISTS", "session:__redigo_tag", "session:user:{__redigo_tag_user}"))
+	assert.Equal(t, 2, n)
+
+	b, err := s.Get(ctx, "__redigo_tag")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	keys, err := s.UserSessions(ctx, "__redigo_tag_user")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"__redigo_tag"}, keys)
+}
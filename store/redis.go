@@ -3,6 +3,7 @@ package store
 import (
 	"bytes"
 	"context"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 
@@ -11,8 +12,14 @@ import (
 
 // Redis is the redis store
 // implement by using "github.com/redis/go-redis/v9" package
+//
+// Client can be any of redis.Client, redis.ClusterClient, failover client or redis.Ring.
+// Sessions are spread across slots by session key, user's index puts user id in hash tag.
+// Session and user's index are written in one transaction with redis.Client,
+// other clients write user's index after session, UserSessions removes keys
+// of deleted or expired sessions from index
 type Redis struct {
-	Client *redis.Client
+	Client redis.UniversalClient
 	Prefix string
 	Coder  session.StoreCoder
 }
@@ -26,7 +33,7 @@ func (s *Redis) coder() session.StoreCoder {
 
 // Get gets session data from redis
 func (s *Redis) Get(ctx context.Context, key string) (session.Data, error) {
-	data, err := s.Client.Get(ctx, s.Prefix+key).Bytes()
+	data, err := s.Client.Get(ctx, s.key(key)).Bytes()
 	if err == redis.Nil {
 		return nil, session.ErrNotFound
 	}
@@ -37,7 +44,7 @@ func (s *Redis) Get(ctx context.Context, key string) (session.Data, error) {
 	var sessData session.Data
 	err = s.coder().NewDecoder(bytes.NewReader(data)).Decode(&sessData)
 	if err != nil {
-		return nil, err
+		return nil, &DecodeError{Err: err}
 	}
 	return sessData, nil
 }
@@ -49,10 +56,222 @@ func (s *Redis) Set(ctx context.Context, key string, value session.Data, opt ses
 	if err != nil {
 		return err
 	}
-	return s.Client.Set(ctx, s.Prefix+key, buf.Bytes(), opt.TTL).Err()
+	if opt.UserID == "" || !s.sameSlot() {
+		err = s.Client.Set(ctx, s.key(key), buf.Bytes(), opt.TTL).Err()
+		if err != nil {
+			return err
+		}
+		return s.updateIndex(ctx, key, opt)
+	}
+
+	_, err = s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.set(ctx, pipe, key, buf.Bytes(), opt)
+		s.index(ctx, pipe, key, opt)
+		return nil
+	})
+	return err
+}
+
+// SetIfVersion sets session data to redis only if stored session version equals to version,
+// stored session is watched to detect concurrent modification
+func (s *Redis) SetIfVersion(ctx context.Context, key string, value session.Data, version int64, opt session.StoreOption) error {
+	var buf bytes.Buffer
+	err := s.coder().NewEncoder(&buf).Encode(value)
+	if err != nil {
+		return err
+	}
+
+	err = s.Client.Watch(ctx, func(tx *redis.Tx) error {
+		var current int64
+		data, err := tx.Get(ctx, s.key(key)).Bytes()
+		if err == nil {
+			var sessData session.Data
+			err = s.coder().NewDecoder(bytes.NewReader(data)).Decode(&sessData)
+			if err != nil {
+				return &DecodeError{Err: err}
+			}
+			current = sessData.Version()
+		} else if err != redis.Nil {
+			return err
+		}
+		if current != version {
+			return session.ErrConflict
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			s.set(ctx, pipe, key, buf.Bytes(), opt)
+			if s.sameSlot() {
+				s.index(ctx, pipe, key, opt)
+			}
+			return nil
+		})
+		return err
+	}, s.key(key))
+	if err == redis.TxFailedErr {
+		return session.ErrConflict
+	}
+	if err != nil || s.sameSlot() {
+		return err
+	}
+	return s.updateIndex(ctx, key, opt)
+}
+
+// redisPatchAttempts is the maximum attempts to patch when session was modified concurrently
+const redisPatchAttempts = 10
+
+// Patch sets and deletes keys in session data,
+// stored session is watched and patch retries when session was modified concurrently
+func (s *Redis) Patch(ctx context.Context, key string, set session.Data, del []string, opt session.StoreOption) error {
+	var err error
+	for i := 0; i < redisPatchAttempts; i++ {
+		err = s.Client.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(ctx, s.key(key)).Bytes()
+			if err == redis.Nil {
+				return session.ErrNotFound
+			}
+			if err != nil {
+				return err
+			}
+
+			var sessData session.Data
+			err = s.coder().NewDecoder(bytes.NewReader(data)).Decode(&sessData)
+			if err != nil {
+				return &DecodeError{Err: err}
+			}
+
+			var buf bytes.Buffer
+			err = s.coder().NewEncoder(&buf).Encode(applyPatch(sessData, set, del))
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				s.set(ctx, pipe, key, buf.Bytes(), opt)
+				if s.sameSlot() {
+					s.index(ctx, pipe, key, opt)
+				}
+				return nil
+			})
+			return err
+		}, s.key(key))
+		if err != redis.TxFailedErr {
+			break
+		}
+	}
+	if err == redis.TxFailedErr {
+		return session.ErrConflict
+	}
+	if err != nil || s.sameSlot() {
+		return err
+	}
+	return s.updateIndex(ctx, key, opt)
+}
+
+// set queues command to set encoded data
+func (s *Redis) set(ctx context.Context, pipe redis.Pipeliner, key string, data []byte, opt session.StoreOption) {
+	pipe.Set(ctx, s.key(key), data, opt.TTL)
+}
+
+// index queues commands to add session key into user's index,
+// index lives as long as the latest saved session
+func (s *Redis) index(ctx context.Context, pipe redis.Pipeliner, key string, opt session.StoreOption) {
+	if opt.UserID == "" {
+		return
+	}
+
+	userKey := s.userKey(opt.UserID)
+	pipe.SAdd(ctx, userKey, key)
+	if opt.TTL > 0 {
+		pipe.Expire(ctx, userKey, opt.TTL)
+	} else {
+		pipe.Persist(ctx, userKey)
+	}
+}
+
+// updateIndex adds session key into user's index,
+// user's index may live in other slot than session key
+func (s *Redis) updateIndex(ctx context.Context, key string, opt session.StoreOption) error {
+	if opt.UserID == "" {
+		return nil
+	}
+	_, err := s.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		s.index(ctx, pipe, key, opt)
+		return nil
+	})
+	return err
 }
 
 // Del deletes session data from redis
 func (s *Redis) Del(ctx context.Context, key string) error {
-	return s.Client.Del(ctx, s.Prefix+key).Err()
+	return s.Client.Del(ctx, s.key(key)).Err()
+}
+
+func (s *Redis) key(key string) string {
+	return s.Prefix + key
+}
+
+func (s *Redis) userKey(userID string) string {
+	return redisUserKey(s.Prefix, userID)
+}
+
+// sameSlot reports whether session key and user's index can be written in one transaction,
+// only single node client has every key in one node
+func (s *Redis) sameSlot() bool {
+	_, ok := s.Client.(*redis.Client)
+	return ok
+}
+
+// redisMilliseconds returns ttl in milliseconds, rounds positive ttl up to at least 1,
+// zero ttl means no expiration
+func redisMilliseconds(ttl time.Duration) int64 {
+	if ttl > 0 && ttl < time.Millisecond {
+		return 1
+	}
+	return ttl.Milliseconds()
+}
+
+// redisUserKey returns key of user's index, user id is the hash tag
+// so keys of the same user live in one slot
+func redisUserKey(prefix, userID string) string {
+	return prefix + "user:{" + userID + "}"
+}
+
+// UserSessions returns keys of sessions that associated with user id
+func (s *Redis) UserSessions(ctx context.Context, userID string) ([]string, error) {
+	userKey := s.userKey(userID)
+	keys, err := s.Client.SMembers(ctx, userKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	exists := make([]*redis.IntCmd, len(keys))
+	_, err = s.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, k := range keys {
+			exists[i] = pipe.Exists(ctx, s.key(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// remove expired sessions from index
+	var r, expired []string
+	for i, k := range keys {
+		if exists[i].Val() > 0 {
+			r = append(r, k)
+		} else {
+			expired = append(expired, k)
+		}
+	}
+	if len(expired) > 0 {
+		err = s.Client.SRem(ctx, userKey, expired).Err()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
 }
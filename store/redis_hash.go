@@ -0,0 +1,238 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/moonrhythm/session"
+)
+
+// RedisHash is the redis store that keeps session data in hash
+// implement by using "github.com/redis/go-redis/v9" package
+//
+// Each session key is a hash field encoded on its own,
+// int64 values are stored as decimal string to support HINCRBY,
+// other values are encoded by Coder.
+type RedisHash struct {
+	Client redis.UniversalClient
+	Prefix string
+	Coder  session.StoreCoder
+}
+
+// redisHashCoded marks field value that was encoded by coder,
+// decimal string never starts with this byte
+const redisHashCoded = 0x00
+
+var redisHashPatchScript = redis.NewScript(`
+if redis.call("exists", KEYS[1]) == 0 then
+	return 0
+end
+local ttl = tonumber(ARGV[1])
+local n = tonumber(ARGV[2])
+local i = 4
+for _ = 1, n do
+	redis.call("hset", KEYS[1], ARGV[i], ARGV[i+1])
+	i = i + 2
+end
+while i <= #ARGV do
+	redis.call("hdel", KEYS[1], ARGV[i])
+	i = i + 1
+end
+if ttl > 0 then
+	redis.call("pexpire", KEYS[1], ttl)
+else
+	redis.call("persist", KEYS[1])
+end
+if KEYS[2] then
+	redis.call("sadd", KEYS[2], ARGV[3])
+	if ttl > 0 then
+		redis.call("pexpire", KEYS[2], ttl)
+	else
+		redis.call("persist", KEYS[2])
+	end
+end
+return 1
+`)
+
+var redisHashIncrScript = redis.NewScript(`
+if redis.call("exists", KEYS[1]) == 0 then
+	return false
+end
+return redis.call("hincrby", KEYS[1], ARGV[1], ARGV[2])
+`)
+
+func (s *RedisHash) coder() session.StoreCoder {
+	if s.Coder == nil {
+		return session.DefaultStoreCoder
+	}
+	return s.Coder
+}
+
+func (s *RedisHash) encodeField(v interface{}) ([]byte, error) {
+	if i, ok := v.(int64); ok {
+		return strconv.AppendInt(nil, i, 10), nil
+	}
+
+	buf := bytes.NewBuffer([]byte{redisHashCoded})
+	err := s.coder().NewEncoder(buf).Encode(&v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *RedisHash) decodeField(b string) (interface{}, error) {
+	if len(b) == 0 || b[0] != redisHashCoded {
+		i, err := strconv.ParseInt(b, 10, 64)
+		if err != nil {
+			return nil, &DecodeError{Err: err}
+		}
+		return i, nil
+	}
+
+	var v interface{}
+	err := s.coder().NewDecoder(bytes.NewReader([]byte(b[1:]))).Decode(&v)
+	if err != nil {
+		return nil, &DecodeError{Err: err}
+	}
+	return v, nil
+}
+
+// Get gets session data from redis
+func (s *RedisHash) Get(ctx context.Context, key string) (session.Data, error) {
+	fields, err := s.Client.HGetAll(ctx, s.key(key)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, session.ErrNotFound
+	}
+
+	sessData := make(session.Data, len(fields))
+	for k, b := range fields {
+		sessData[k], err = s.decodeField(b)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return sessData, nil
+}
+
+// GetField gets a field from session data
+func (s *RedisHash) GetField(ctx context.Context, key, field string) (interface{}, error) {
+	b, err := s.Client.HGet(ctx, s.key(key), field).Result()
+	if err == redis.Nil {
+		return nil, session.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.decodeField(b)
+}
+
+// Set sets session data to redis, replaces all fields
+func (s *RedisHash) Set(ctx context.Context, key string, value session.Data, opt session.StoreOption) error {
+	fields := make(map[string]interface{}, len(value))
+	for k, v := range value {
+		b, err := s.encodeField(v)
+		if err != nil {
+			return err
+		}
+		fields[k] = b
+	}
+
+	// transaction updates user's index only when it lives in the same slot as session key
+	sameSlot := s.redis().sameSlot()
+	_, err := s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.key(key))
+		if len(fields) == 0 {
+			return nil
+		}
+		pipe.HSet(ctx, s.key(key), fields)
+		if opt.TTL > 0 {
+			pipe.PExpire(ctx, s.key(key), opt.TTL)
+		}
+		if sameSlot {
+			s.redis().index(ctx, pipe, key, opt)
+		}
+		return nil
+	})
+	if err != nil || sameSlot || len(fields) == 0 {
+		return err
+	}
+	return s.redis().updateIndex(ctx, key, opt)
+}
+
+// Patch sets and deletes fields in session data using HSET and HDEL
+func (s *RedisHash) Patch(ctx context.Context, key string, set session.Data, del []string, opt session.StoreOption) error {
+	args := make([]interface{}, 0, 3+2*len(set)+len(del))
+	args = append(args, redisMilliseconds(opt.TTL), len(set), key)
+	for k, v := range set {
+		b, err := s.encodeField(v)
+		if err != nil {
+			return err
+		}
+		args = append(args, k, b)
+	}
+	for _, k := range del {
+		args = append(args, k)
+	}
+
+	// script updates user's index only when it lives in the same slot as session key
+	keys := []string{s.key(key)}
+	sameSlot := s.redis().sameSlot()
+	if sameSlot && opt.UserID != "" {
+		keys = append(keys, s.userKey(opt.UserID))
+	}
+
+	ok, err := redisHashPatchScript.Run(ctx, s.Client, keys, args...).Int()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return session.ErrNotFound
+	}
+	if sameSlot {
+		return nil
+	}
+	return s.redis().updateIndex(ctx, key, opt)
+}
+
+// Incr increments int64 field in session data by n using HINCRBY,
+// returns value after increment
+func (s *RedisHash) Incr(ctx context.Context, key, field string, n int64) (int64, error) {
+	r, err := redisHashIncrScript.Run(ctx, s.Client, []string{s.key(key)}, field, n).Int64()
+	if err == redis.Nil {
+		return 0, session.ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return r, nil
+}
+
+// Del deletes session data from redis
+func (s *RedisHash) Del(ctx context.Context, key string) error {
+	return s.Client.Del(ctx, s.key(key)).Err()
+}
+
+func (s *RedisHash) key(key string) string {
+	return s.Prefix + key
+}
+
+func (s *RedisHash) userKey(userID string) string {
+	return s.redis().userKey(userID)
+}
+
+// redis returns Redis store that shares key naming, uses to maintain user's index
+func (s *RedisHash) redis() *Redis {
+	return &Redis{Client: s.Client, Prefix: s.Prefix}
+}
+
+// UserSessions returns keys of sessions that associated with user id
+func (s *RedisHash) UserSessions(ctx context.Context, userID string) ([]string, error) {
+	return s.redis().UserSessions(ctx, userID)
+}
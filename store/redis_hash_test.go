@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/moonrhythm/session"
+)
+
+func TestRedisHash(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &RedisHash{
+		Prefix: "session:",
+		Client: redis.NewClient(&redis.Options{
+			Addr: redisAddr(),
+		}),
+	}
+
+	opt := session.StoreOption{TTL: time.Second}
+
+	data := make(session.Data)
+	data["test"] = "123"
+	data["count"] = int64(1)
+	data["n"] = 2
+
+	err := s.Set(ctx, "__redis_hash", data, opt)
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "__redis_hash")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	raw, _ := s.Client.HGet(ctx, "session:__redis_hash", "count").Result()
+	assert.Equal(t, "1", raw, "expected int64 stored as decimal")
+
+	v, err := s.GetField(ctx, "__redis_hash", "test")
+	assert.NoError(t, err)
+	assert.Equal(t, "123", v)
+
+	_, err = s.GetField(ctx, "__redis_hash", "notfound")
+	assert.Equal(t, session.ErrNotFound, err)
+
+	ttl, _ := s.Client.PTTL(ctx, "session:__redis_hash").Result()
+	assert.True(t, ttl > 0 && ttl <= time.Second)
+
+	time.Sleep(2 * time.Second)
+
+	_, err = s.Get(ctx, "__redis_hash")
+	assert.Equal(t, session.ErrNotFound, err)
+}
+
+func TestRedisHashWithoutTTL(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &RedisHash{
+		Prefix: "session:",
+		Client: redis.NewClient(&redis.Options{
+			Addr: redisAddr(),
+		}),
+	}
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	err := s.Set(ctx, "__redis_hash_without_ttl", data, session.StoreOption{})
+	assert.NoError(t, err)
+
+	ttl, _ := s.Client.TTL(ctx, "session:__redis_hash_without_ttl").Result()
+	assert.Equal(t, time.Duration(-1), ttl)
+
+	s.Del(ctx, "__redis_hash_without_ttl")
+}
+
+func TestRedisHashPatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &RedisHash{
+		Prefix: "session:",
+		Client: redis.NewClient(&redis.Options{
+			Addr: redisAddr(),
+		}),
+	}
+
+	opt := session.StoreOption{TTL: time.Minute}
+
+	s.Del(ctx, "__redis_hash_patch")
+	err := s.Patch(ctx, "__redis_hash_patch", session.Data{"a": 1}, nil, opt)
+	assert.Equal(t, session.ErrNotFound, err)
+
+	s.Set(ctx, "__redis_hash_patch", session.Data{"a": 1, "b": 2, "c": 3}, opt)
+
+	err = s.Patch(ctx, "__redis_hash_patch", session.Data{"a": 10}, nil, opt)
+	assert.NoError(t, err)
+	err = s.Patch(ctx, "__redis_hash_patch", session.Data{"d": 4}, []string{"b"}, opt)
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "__redis_hash_patch")
+	assert.NoError(t, err)
+	assert.Equal(t, session.Data{"a": 10, "c": 3, "d": 4}, b)
+}
+
+func TestRedisHashIncr(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &RedisHash{
+		Prefix: "session:",
+		Client: redis.NewClient(&redis.Options{
+			Addr: redisAddr(),
+		}),
+	}
+
+	s.Del(ctx, "__redis_hash_incr")
+	_, err := s.Incr(ctx, "__redis_hash_incr", "count", 1)
+	assert.Equal(t, session.ErrNotFound, err)
+
+	exists, _ := s.Client.Exists(ctx, "session:__redis_hash_incr").Result()
+	assert.EqualValues(t, 0, exists, "expected incr not create session")
+
+	s.Set(ctx, "__redis_hash_incr", session.Data{"count": int64(1)}, session.StoreOption{TTL: time.Minute})
+
+	n, err := s.Incr(ctx, "__redis_hash_incr", "count", 2)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+
+	b, err := s.Get(ctx, "__redis_hash_incr")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), b["count"])
+}
+
+func TestRedisHashUserSessions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &RedisHash{
+		Prefix: "session:",
+		Client: redis.NewClient(&redis.Options{
+			Addr: redisAddr(),
+		}),
+	}
+
+	data := session.Data{"test": "123"}
+	opt := session.StoreOption{UserID: "__redis_hash_user1", TTL: time.Minute}
+
+	s.Client.Del(ctx, s.userKey("__redis_hash_user1"))
+	s.Set(ctx, "__redis_hash_user_a", data, opt)
+	s.Set(ctx, "__redis_hash_user_b", data, session.StoreOption{TTL: time.Minute})
+	s.Patch(ctx, "__redis_hash_user_b", data, nil, opt)
+
+	keys, err := s.UserSessions(ctx, "__redis_hash_user1")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"__redis_hash_user_a", "__redis_hash_user_b"}, keys)
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -84,3 +85,247 @@ func TestRedisWithoutTTL(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, data, b)
 }
+
+func TestRedisUserSessions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Redis{
+		Prefix: "session:",
+		Client: redis.NewClient(&redis.Options{
+			Addr: redisAddr(),
+		}),
+	}
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	s.Client.Del(ctx, s.userKey("__redis_user1"))
+	s.Set(ctx, "__redis_user_a", data, session.StoreOption{UserID: "__redis_user1", TTL: time.Minute})
+	s.Set(ctx, "__redis_user_b", data, session.StoreOption{UserID: "__redis_user1", TTL: time.Minute})
+
+	keys, err := s.UserSessions(ctx, "__redis_user1")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"__redis_user_a", "__redis_user_b"}, keys)
+
+	s.Del(ctx, "__redis_user_a")
+	keys, err = s.UserSessions(ctx, "__redis_user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"__redis_user_b"}, keys)
+
+	n, _ := s.Client.SCard(ctx, s.userKey("__redis_user1")).Result()
+	assert.EqualValues(t, 1, n, "expected deleted session removed from index")
+}
+
+func TestRedisSetIfVersion(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Redis{
+		Prefix: "session:",
+		Client: redis.NewClient(&redis.Options{
+			Addr: redisAddr(),
+		}),
+	}
+
+	opt := session.StoreOption{TTL: time.Minute}
+	data := session.Data{"test": "123", "_session/version": int64(1)}
+
+	s.Del(ctx, "__redis_version")
+	err := s.SetIfVersion(ctx, "__redis_version", data, 1, opt)
+	assert.Equal(t, session.ErrConflict, err, "expected conflict when session not exists")
+
+	err = s.SetIfVersion(ctx, "__redis_version", data, 0, opt)
+	assert.NoError(t, err)
+
+	err = s.SetIfVersion(ctx, "__redis_version", data, 0, opt)
+	assert.Equal(t, session.ErrConflict, err)
+
+	data = session.Data{"test": "456", "_session/version": int64(2)}
+	err = s.SetIfVersion(ctx, "__redis_version", data, 1, opt)
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "__redis_version")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+}
+
+func TestRedisPatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Redis{
+		Prefix: "session:",
+		Client: redis.NewClient(&redis.Options{
+			Addr: redisAddr(),
+		}),
+	}
+
+	opt := session.StoreOption{TTL: time.Minute}
+
+	s.Del(ctx, "__redis_patch")
+	err := s.Patch(ctx, "__redis_patch", session.Data{"a": 1}, nil, opt)
+	assert.Equal(t, session.ErrNotFound, err)
+
+	s.Set(ctx, "__redis_patch", session.Data{"a": 1, "b": 2, "c": 3}, opt)
+
+	err = s.Patch(ctx, "__redis_patch", session.Data{"a": 10}, nil, opt)
+	assert.NoError(t, err)
+	err = s.Patch(ctx, "__redis_patch", session.Data{"d": 4}, []string{"b"}, opt)
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "__redis_patch")
+	assert.NoError(t, err)
+	assert.Equal(t, session.Data{"a": 10, "c": 3, "d": 4}, b)
+}
+
+// redisKeySlot returns Redis Cluster slot of key, crc16 (XMODEM) of hash tag or whole key mod 16384
+func redisKeySlot(key string) int {
+	if i := strings.IndexByte(key, '{'); i >= 0 {
+		if j := strings.IndexByte(key[i+1:], '}'); j > 0 {
+			key = key[i+1 : i+1+j]
+		}
+	}
+
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return int(crc % 16384)
+}
+
+func TestRedisKeySlot(t *testing.T) {
+	t.Parallel()
+
+	// test vectors from redis cluster specification
+	assert.Equal(t, 12182, redisKeySlot("foo"))
+	assert.Equal(t, redisKeySlot("user1000"), redisKeySlot("{user1000}.following"))
+	assert.NotEqual(t, redisKeySlot("{}{user1000}"), redisKeySlot("{}{user1000}.following"), "expected empty hash tag ignored")
+}
+
+func TestRedisHashTag(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Redis{
+		Prefix: "session:",
+		Client: redis.NewClient(&redis.Options{
+			Addr: redisAddr(),
+		}),
+	}
+
+	// sessions spread across slots, user's index lives in slot of user id
+	assert.NotEqual(t, redisKeySlot(s.key("__redis_tag_a")), redisKeySlot(s.key("__redis_tag_b")))
+	assert.Equal(t, redisKeySlot("__redis_tag_user"), redisKeySlot(s.userKey("__redis_tag_user")))
+	assert.Equal(t, s.userKey("__redis_tag_user"), (&RedisHash{Prefix: "session:"}).userKey("__redis_tag_user"))
+	assert.Equal(t, s.userKey("__redis_tag_user"), (&Redigo{Prefix: "session:"}).userKey("__redis_tag_user"))
+
+	data := session.Data{"test": "123"}
+	opt := session.StoreOption{UserID: "__redis_tag_user", TTL: time.Minute}
+
+	s.Client.Del(ctx, s.userKey("__redis_tag_user"))
+	err := s.Set(ctx, "__redis_tag_a", data, opt)
+	assert.NoError(t, err)
+
+	n, _ := s.Client.Exists(ctx, "session:__redis_tag_a", "session:user:{__redis_tag_user}").Result()
+	assert.EqualValues(t, 2, n)
+}
+
+func TestRedisRing(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	// ring writes user's index after session
+	s := &Redis{
+		Prefix: "session:",
+		Client: redis.NewRing(&redis.RingOptions{
+			Addrs: map[string]string{"shard": redisAddr()},
+		}),
+	}
+
+	opt := session.StoreOption{UserID: "__redis_ring_user", TTL: time.Minute}
+	data := session.Data{"test": "123", "_session/version": int64(1)}
+
+	s.Del(ctx, "__redis_ring_a")
+	s.Client.Del(ctx, s.userKey("__redis_ring_user"))
+
+	err := s.SetIfVersion(ctx, "__redis_ring_a", data, 0, opt)
+	assert.NoError(t, err)
+
+	err = s.Patch(ctx, "__redis_ring_a", session.Data{"a": 1}, nil, opt)
+	assert.NoError(t, err)
+
+	err = s.Set(ctx, "__redis_ring_b", data, opt)
+	assert.NoError(t, err)
+
+	keys, err := s.UserSessions(ctx, "__redis_ring_user")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"__redis_ring_a", "__redis_ring_b"}, keys)
+
+	// deleted session is removed from index on read
+	s.Del(ctx, "__redis_ring_b")
+	keys, err = s.UserSessions(ctx, "__redis_ring_user")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"__redis_ring_a"}, keys)
+}
+
+func TestRedisCluster(t *testing.T) {
+	t.Parallel()
+
+	addrs := os.Getenv("REDIS_CLUSTER_ADDRS")
+	if addrs == "" {
+		t.Skip("REDIS_CLUSTER_ADDRS not set")
+	}
+
+	ctx := context.Background()
+
+	s := &Redis{
+		Prefix: "session:",
+		Client: redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs: strings.Split(addrs, ","),
+		}),
+	}
+
+	opt := session.StoreOption{UserID: "__redis_cluster_user", TTL: time.Minute}
+	data := session.Data{"test": "123", "_session/version": int64(1)}
+
+	s.Del(ctx, "__redis_cluster_a")
+	s.Client.Del(ctx, s.userKey("__redis_cluster_user"))
+
+	// compare with slot that computed by cluster
+	slot, err := s.Client.ClusterKeySlot(ctx, s.key("__redis_cluster_a")).Result()
+	assert.NoError(t, err)
+	assert.EqualValues(t, redisKeySlot(s.key("__redis_cluster_a")), slot)
+	userSlot, err := s.Client.ClusterKeySlot(ctx, s.userKey("__redis_cluster_user")).Result()
+	assert.NoError(t, err)
+	assert.EqualValues(t, redisKeySlot("__redis_cluster_user"), userSlot)
+
+	err = s.SetIfVersion(ctx, "__redis_cluster_a", data, 0, opt)
+	assert.NoError(t, err)
+
+	err = s.Patch(ctx, "__redis_cluster_a", session.Data{"a": 1}, nil, opt)
+	assert.NoError(t, err)
+
+	err = s.Set(ctx, "__redis_cluster_b", data, opt)
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "__redis_cluster_a")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, b["a"])
+
+	keys, err := s.UserSessions(ctx, "__redis_cluster_user")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"__redis_cluster_a", "__redis_cluster_b"}, keys)
+}
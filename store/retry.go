@@ -2,60 +2,176 @@ package store
 
 import (
 	"context"
+	"errors"
+	"math/rand"
 	"time"
 
 	"github.com/moonrhythm/session"
 )
 
 // Retry reties store operation when failed
+//
+// Retry waits with exponential backoff and jitter between attempts,
+// stops when ctx is done or error is not retryable
 type Retry struct {
-	Store       session.Store
+	Store session.Store
+
+	// MaxAttempts is the maximum attempts of each operation, default is 3
 	MaxAttempts int
+
+	// GetAttempts, SetAttempts and DelAttempts override MaxAttempts for each operation
+	GetAttempts int
+	SetAttempts int
+	DelAttempts int
+
+	// BaseDelay is the backoff before the second attempt, default is 100 milliseconds,
+	// backoff doubles on each attempt
+	BaseDelay time.Duration
+
+	// MaxDelay is the maximum backoff, default is 2 seconds
+	MaxDelay time.Duration
+
+	// Retryable reports whether operation should be retried on err,
+	// default is DefaultRetryable
+	Retryable func(err error) bool
 }
 
-func (s *Retry) maxAttempts() int {
+// DefaultRetryable reports whether err may succeed on retry,
+// session errors, context errors and decode errors are not retryable
+func DefaultRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var decodeErr *DecodeError
+	switch {
+	case errors.Is(err, session.ErrNotFound),
+		errors.Is(err, session.ErrConflict),
+		errors.Is(err, session.ErrNotSupported),
+		errors.Is(err, context.Canceled),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &decodeErr):
+		return false
+	}
+	return true
+}
+
+func (s *Retry) maxAttempts(n int) int {
+	if n > 0 {
+		return n
+	}
 	if s.MaxAttempts <= 0 {
 		return 3
 	}
 	return s.MaxAttempts
 }
 
-func (s *Retry) backOffDuration() time.Duration {
-	return 100 * time.Millisecond
+func (s *Retry) retryable(err error) bool {
+	if s.Retryable == nil {
+		return DefaultRetryable(err)
+	}
+	return s.Retryable(err)
+}
+
+// backOffDuration returns backoff before given retry (start from 1),
+// half of backoff is random to spread retries from many clients
+func (s *Retry) backOffDuration(retry int) time.Duration {
+	base := s.BaseDelay
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	max := s.MaxDelay
+	if max <= 0 {
+		max = 2 * time.Second
+	}
+
+	d := base
+	for i := 1; i < retry && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// do calls f until success, attempts exhausted, error is not retryable or ctx is done
+func (s *Retry) do(ctx context.Context, attempts int, f func() error) (err error) {
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			t := time.NewTimer(s.backOffDuration(i))
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+		}
+
+		err = f()
+		if err == nil || !s.retryable(err) {
+			return
+		}
+	}
+	return
 }
 
 // Get gets session data from wrapped store with retry
 func (s *Retry) Get(ctx context.Context, key string) (r session.Data, err error) {
-	for i := 0; i < s.MaxAttempts; i++ {
+	err = s.do(ctx, s.maxAttempts(s.GetAttempts), func() (err error) {
 		r, err = s.Store.Get(ctx, key)
-		if err == nil || err == session.ErrNotFound {
-			break
-		}
-		time.Sleep(s.backOffDuration())
-	}
+		return
+	})
 	return
 }
 
 // Set sets session data to wrapped store with retry
-func (s *Retry) Set(ctx context.Context, key string, value session.Data, opt session.StoreOption) (err error) {
-	for i := 0; i < s.MaxAttempts; i++ {
-		err = s.Store.Set(ctx, key, value, opt)
-		if err == nil {
-			break
-		}
-		time.Sleep(s.backOffDuration())
-	}
-	return
+func (s *Retry) Set(ctx context.Context, key string, value session.Data, opt session.StoreOption) error {
+	return s.do(ctx, s.maxAttempts(s.SetAttempts), func() error {
+		return s.Store.Set(ctx, key, value, opt)
+	})
 }
 
 // Del deletes session data from wrapped store with retry
-func (s *Retry) Del(ctx context.Context, key string) (err error) {
-	for i := 0; i < s.MaxAttempts; i++ {
-		err = s.Store.Del(ctx, key)
-		if err == nil {
-			break
-		}
-		time.Sleep(s.backOffDuration())
+func (s *Retry) Del(ctx context.Context, key string) error {
+	return s.do(ctx, s.maxAttempts(s.DelAttempts), func() error {
+		return s.Store.Del(ctx, key)
+	})
+}
+
+// SetIfVersion sets session data to wrapped store with retry,
+// returns session.ErrNotSupported if wrapped store is not session.VersionedStore
+func (s *Retry) SetIfVersion(ctx context.Context, key string, value session.Data, version int64, opt session.StoreOption) error {
+	vs, ok := s.Store.(session.VersionedStore)
+	if !ok {
+		return session.ErrNotSupported
+	}
+	return s.do(ctx, s.maxAttempts(s.SetAttempts), func() error {
+		return vs.SetIfVersion(ctx, key, value, version, opt)
+	})
+}
+
+// Patch patches session data in wrapped store with retry,
+// returns session.ErrNotSupported if wrapped store is not session.PatchStore
+func (s *Retry) Patch(ctx context.Context, key string, set session.Data, del []string, opt session.StoreOption) error {
+	ps, ok := s.Store.(session.PatchStore)
+	if !ok {
+		return session.ErrNotSupported
+	}
+	return s.do(ctx, s.maxAttempts(s.SetAttempts), func() error {
+		return ps.Patch(ctx, key, set, del, opt)
+	})
+}
+
+// UserSessions returns keys of sessions that associated with user id from wrapped store with retry,
+// returns session.ErrNotSupported if wrapped store is not session.UserStore
+func (s *Retry) UserSessions(ctx context.Context, userID string) (r []string, err error) {
+	us, ok := s.Store.(session.UserStore)
+	if !ok {
+		return nil, session.ErrNotSupported
 	}
+	err = s.do(ctx, s.maxAttempts(s.GetAttempts), func() (err error) {
+		r, err = us.UserSessions(ctx, userID)
+		return
+	})
 	return
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -81,3 +82,128 @@ func TestRetryFail(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+type errStore struct {
+	err     error
+	attempt int
+}
+
+func (s *errStore) Get(ctx context.Context, key string) (session.Data, error) {
+	s.attempt++
+	return nil, s.err
+}
+
+func (s *errStore) Set(ctx context.Context, key string, value session.Data, opt session.StoreOption) error {
+	s.attempt++
+	return s.err
+}
+
+func (s *errStore) Del(ctx context.Context, key string) error {
+	s.attempt++
+	return s.err
+}
+
+func TestRetryAttempts(t *testing.T) {
+	ctx := context.Background()
+
+	m := &errStore{err: fmt.Errorf("error")}
+	s := &Retry{Store: m, BaseDelay: time.Millisecond, SetAttempts: 5}
+
+	_, err := s.Get(ctx, "")
+	assert.Error(t, err)
+	assert.Equal(t, 3, m.attempt, "expected default max attempts")
+
+	m.attempt = 0
+	err = s.Set(ctx, "", session.Data{}, session.StoreOption{})
+	assert.Error(t, err)
+	assert.Equal(t, 5, m.attempt)
+
+	m.attempt = 0
+	s.MaxAttempts = 2
+	err = s.Del(ctx, "")
+	assert.Error(t, err)
+	assert.Equal(t, 2, m.attempt)
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	ctx := context.Background()
+
+	for _, e := range []error{
+		session.ErrNotFound,
+		session.ErrConflict,
+		context.Canceled,
+		&DecodeError{Err: fmt.Errorf("gob: bad data")},
+		fmt.Errorf("wrapped: %w", context.DeadlineExceeded),
+	} {
+		m := &errStore{err: e}
+		s := &Retry{Store: m, BaseDelay: time.Millisecond}
+		_, err := s.Get(ctx, "")
+		assert.Equal(t, e, err)
+		assert.Equal(t, 1, m.attempt, "expected %v not retry", e)
+	}
+
+	m := &errStore{err: fmt.Errorf("error")}
+	s := &Retry{Store: m, BaseDelay: time.Millisecond, Retryable: func(err error) bool { return false }}
+	s.Set(ctx, "", session.Data{}, session.StoreOption{})
+	assert.Equal(t, 1, m.attempt)
+}
+
+func TestRetryContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m := &errStore{err: fmt.Errorf("error")}
+	s := &Retry{Store: m, BaseDelay: time.Second}
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+	start := time.Now()
+	err := s.Set(ctx, "", session.Data{}, session.StoreOption{})
+	assert.Error(t, err)
+	assert.Equal(t, 1, m.attempt)
+	assert.Less(t, time.Since(start), 500*time.Millisecond, "expected wait stops when ctx done")
+}
+
+func TestRetryBackOff(t *testing.T) {
+	s := &Retry{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for i, max := range []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	} {
+		d := s.backOffDuration(i + 1)
+		assert.GreaterOrEqual(t, d, max/2)
+		assert.LessOrEqual(t, d, max)
+	}
+}
+
+func TestRetryForward(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	s := &Retry{Store: new(Memory)}
+	opt := session.StoreOption{TTL: time.Minute, UserID: "user1"}
+
+	err := s.SetIfVersion(ctx, "a", session.Data{"test": "1", "_session/version": int64(1)}, 0, opt)
+	assert.NoError(t, err)
+	err = s.SetIfVersion(ctx, "a", session.Data{"test": "2", "_session/version": int64(1)}, 0, opt)
+	assert.Equal(t, session.ErrConflict, err)
+
+	err = s.Patch(ctx, "a", session.Data{"test": "3"}, nil, opt)
+	assert.NoError(t, err)
+	b, _ := s.Get(ctx, "a")
+	assert.Equal(t, "3", b["test"])
+
+	keys, err := s.UserSessions(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, keys)
+
+	s = &Retry{Store: &mockStore{}}
+	assert.Equal(t, session.ErrNotSupported, s.SetIfVersion(ctx, "a", session.Data{}, 0, opt))
+	assert.Equal(t, session.ErrNotSupported, s.Patch(ctx, "a", session.Data{}, nil, opt))
+	_, err = s.UserSessions(ctx, "user1")
+	assert.Equal(t, session.ErrNotSupported, err)
+}
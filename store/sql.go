@@ -6,7 +6,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"strconv"
 	"time"
 
 	"github.com/moonrhythm/session"
@@ -17,29 +17,129 @@ type SQL struct {
 	DB    *sql.DB
 	Coder session.StoreCoder
 
+	// Metadata extracts metadata from session data to store in columns,
+	// metadata columns are empty if Metadata is nil
+	Metadata func(data session.Data) SQLMetadata
+
+	// Statements take positional arguments in the documented order,
+	// new arguments are only appended to the end.
+	// Statements that have fewer placeholders receive only the leading arguments,
+	// so statements written for tables without new columns still work
+
+	// SetStatement upserts session data without change created at,
+	// arguments are id, value, created at, expires at, user id, version,
+	// updated at, last seen at, ip and user agent
 	SetStatement string
+
+	// GetStatement selects session data, argument is id
 	GetStatement string
+
+	// DelStatement deletes session, argument is id
 	DelStatement string
-	GCStatement  string
+
+	// GCStatement deletes expired sessions, no argument
+	GCStatement string
+
+	// UserSessionsStatement selects ids of not expired sessions, argument is user id
+	UserSessionsStatement string
+
+	// SetNewStatement inserts session data only if session not exists or expired,
+	// uses when save session that has version 0 with optimistic lock,
+	// SetVersionStatement is used after it to update session that stored with version 0.
+	// Arguments are the same as SetStatement
+	SetNewStatement string
+
+	// SetVersionStatement updates session data only if session version matched,
+	// uses when save existing session with optimistic lock.
+	// Arguments are id, value, expires at, user id, new version, expected version,
+	// updated at, last seen at, ip and user agent
+	SetVersionStatement string
+
+	// GetForUpdateStatement selects session data and locks it until transaction end,
+	// uses when patch session data, argument is id
+	GetForUpdateStatement string
+
+	// OnError is called when init schema or gc fails, optional,
+	// must be set before generate statement to receive init schema error
+	OnError func(err error)
+
+	w worker
+}
+
+// SQLMetadata is the session metadata that stores in columns,
+// for querying sessions with plain sql
+type SQLMetadata struct {
+	// UserID uses when session is not associated with user
+	UserID string
+
+	// LastSeen is the last activity time, zero means save time
+	LastSeen time.Time
+
+	IP        string
+	UserAgent string
 }
 
 const (
-	pgsqlInitSchema = `create table if not exists %s (
+	pgsqlInitSchema = `create table if not exists %[1]s (
     id varchar,
     value bytea not null,
     created_at timestamptz not null default now(),
     expires_at timestamptz,
+    user_id varchar,
+    version bigint not null default 0,
+    updated_at timestamptz not null default now(),
+    last_seen_at timestamptz,
+    ip varchar,
+    user_agent varchar,
     primary key (id)
 );
-create index if not exists %s_expires_at_idx on %s (expires_at);`
-	pgsqlSet = `insert into %s (id, value, created_at, expires_at)
-values ($1, $2, $3, $4)
+alter table %[1]s add column if not exists user_id varchar;
+alter table %[1]s add column if not exists version bigint not null default 0;
+alter table %[1]s add column if not exists updated_at timestamptz not null default now();
+alter table %[1]s add column if not exists last_seen_at timestamptz;
+alter table %[1]s add column if not exists ip varchar;
+alter table %[1]s add column if not exists user_agent varchar;
+create index if not exists %[1]s_expires_at_idx on %[1]s (expires_at);
+create index if not exists %[1]s_user_id_idx on %[1]s (user_id);`
+	pgsqlSet = `insert into %s (id, value, created_at, expires_at, user_id, version, updated_at, last_seen_at, ip, user_agent)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 on conflict (id) do update
 set value = excluded.value,
-    expires_at = excluded.expires_at`
-	pgsqlGet = `select value from %s where id = $1 and (expires_at is null or expires_at > now())`
-	pgsqlDel = `delete from %s where id = $1`
-	pgsqlGC  = `delete from %s where expires_at <= now()`
+    expires_at = excluded.expires_at,
+    user_id = excluded.user_id,
+    version = excluded.version,
+    updated_at = excluded.updated_at,
+    last_seen_at = excluded.last_seen_at,
+    ip = excluded.ip,
+    user_agent = excluded.user_agent`
+	pgsqlSetNew = `insert into %[1]s (id, value, created_at, expires_at, user_id, version, updated_at, last_seen_at, ip, user_agent)
+values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+on conflict (id) do update
+set value = excluded.value,
+    created_at = excluded.created_at,
+    expires_at = excluded.expires_at,
+    user_id = excluded.user_id,
+    version = excluded.version,
+    updated_at = excluded.updated_at,
+    last_seen_at = excluded.last_seen_at,
+    ip = excluded.ip,
+    user_agent = excluded.user_agent
+where %[1]s.expires_at <= now()`
+	pgsqlSetVersion = `update %s
+set value = $2,
+    expires_at = $3,
+    user_id = $4,
+    version = $5,
+    updated_at = $7,
+    last_seen_at = $8,
+    ip = $9,
+    user_agent = $10
+where id = $1 and version = $6 and (expires_at is null or expires_at > now())`
+	pgsqlGet          = `select value from %s where id = $1 and (expires_at is null or expires_at > now())`
+	pgsqlGetForUpdate = `select value from %s where id = $1 and (expires_at is null or expires_at > now()) for update`
+	pgsqlDel          = `delete from %s where id = $1`
+	pgsqlGC           = `delete from %s where expires_at <= now()`
+	pgsqlUserSessions = `select id from %s where user_id = $1 and (expires_at is null or expires_at > now())`
 )
 
 func (s *SQL) coder() session.StoreCoder {
@@ -52,20 +152,31 @@ func (s *SQL) coder() session.StoreCoder {
 // GeneratePostgrSQLStatement generates postgresql statement
 func (s *SQL) GeneratePostgreSQLStatement(table string, initSchema bool) *SQL {
 	if initSchema {
-		q := fmt.Sprintf(pgsqlInitSchema, table, table, table)
-		_, err := s.DB.Exec(q)
-		if err != nil {
-			log.Printf("store/sql: init postgresql schema error: %v", err)
-		}
+		s.initSchema("postgresql", fmt.Sprintf(pgsqlInitSchema, table))
 	}
 
 	s.SetStatement = fmt.Sprintf(pgsqlSet, table)
 	s.GetStatement = fmt.Sprintf(pgsqlGet, table)
 	s.DelStatement = fmt.Sprintf(pgsqlDel, table)
 	s.GCStatement = fmt.Sprintf(pgsqlGC, table)
+	s.UserSessionsStatement = fmt.Sprintf(pgsqlUserSessions, table)
+	s.SetNewStatement = fmt.Sprintf(pgsqlSetNew, table)
+	s.SetVersionStatement = fmt.Sprintf(pgsqlSetVersion, table)
+	s.GetForUpdateStatement = fmt.Sprintf(pgsqlGetForUpdate, table)
 	return s
 }
 
+// initSchema executes schema statements in order, stops at the first error
+func (s *SQL) initSchema(dialect string, stmts ...string) {
+	for _, q := range stmts {
+		_, err := s.DB.Exec(q)
+		if err != nil {
+			handleError(s.OnError, fmt.Errorf("store/sql: init %s schema: %w", dialect, err))
+			return
+		}
+	}
+}
+
 // Get gets session data from sql db
 func (s *SQL) Get(ctx context.Context, key string) (session.Data, error) {
 	var b []byte
@@ -73,32 +184,201 @@ func (s *SQL) Get(ctx context.Context, key string) (session.Data, error) {
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, session.ErrNotFound
 	}
+	if err != nil {
+		return nil, err
+	}
 
 	var sessData session.Data
 	err = s.coder().NewDecoder(bytes.NewReader(b)).Decode(&sessData)
 	if err != nil {
-		return nil, err
+		return nil, &DecodeError{Err: err}
 	}
 	return sessData, nil
 }
 
 // Set sets session data to sql db
 func (s *SQL) Set(ctx context.Context, key string, value session.Data, opt session.StoreOption) error {
+	r, err := s.makeRow(key, value, opt)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.ExecContext(ctx, s.SetStatement, statementArgs(s.SetStatement, r.setArgs())...)
+	return err
+}
+
+// SetIfVersion sets session data to sql db only if stored session version equals to version
+func (s *SQL) SetIfVersion(ctx context.Context, key string, value session.Data, version int64, opt session.StoreOption) error {
+	r, err := s.makeRow(key, value, opt)
+	if err != nil {
+		return err
+	}
+
+	if version == 0 {
+		ok, err := s.exec(ctx, s.SetNewStatement, statementArgs(s.SetNewStatement, r.setArgs())...)
+		if err != nil || ok {
+			return err
+		}
+		// session was saved before enable optimistic lock, stored version is 0
+	}
+
+	ok, err := s.exec(ctx, s.SetVersionStatement, statementArgs(s.SetVersionStatement, r.setVersionArgs(version))...)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return session.ErrConflict
+	}
+	return nil
+}
+
+// exec executes statement, reports whether any row was affected
+func (s *SQL) exec(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	res, err := s.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Patch sets and deletes keys in session data,
+// session is locked by GetForUpdateStatement while patching
+func (s *SQL) Patch(ctx context.Context, key string, set session.Data, del []string, opt session.StoreOption) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var b []byte
+	err = tx.QueryRowContext(ctx, s.GetForUpdateStatement, key).Scan(&b)
+	if errors.Is(err, sql.ErrNoRows) {
+		return session.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	var sessData session.Data
+	err = s.coder().NewDecoder(bytes.NewReader(b)).Decode(&sessData)
+	if err != nil {
+		return &DecodeError{Err: err}
+	}
+
+	r, err := s.makeRow(key, applyPatch(sessData, set, del), opt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, s.SetStatement, statementArgs(s.SetStatement, r.setArgs())...)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqlRow is the column values to set
+type sqlRow struct {
+	id        string
+	value     []byte
+	now       time.Time
+	expiresAt sql.NullTime
+	userID    sql.NullString
+	version   int64
+	lastSeen  sql.NullTime
+	ip        sql.NullString
+	userAgent sql.NullString
+}
+
+// makeRow encodes session data and extracts metadata into row,
+// times are in UTC to compare as text in databases that do not have time type
+func (s *SQL) makeRow(key string, value session.Data, opt session.StoreOption) (*sqlRow, error) {
 	var buf bytes.Buffer
 	err := s.coder().NewEncoder(&buf).Encode(value)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	now := time.Now()
-	var exp sql.NullTime
+	r := sqlRow{
+		id:      key,
+		value:   buf.Bytes(),
+		now:     time.Now().UTC(),
+		userID:  nullString(opt.UserID),
+		version: value.Version(),
+	}
 	if opt.TTL > 0 {
-		exp.Valid = true
-		exp.Time = now.Add(opt.TTL)
+		r.expiresAt = sql.NullTime{Time: r.now.Add(opt.TTL), Valid: true}
 	}
 
-	_, err = s.DB.ExecContext(ctx, s.SetStatement, key, buf.Bytes(), now, exp)
-	return err
+	if s.Metadata != nil {
+		md := s.Metadata(value)
+		if !r.userID.Valid {
+			r.userID = nullString(md.UserID)
+		}
+		r.lastSeen = sql.NullTime{Time: r.now, Valid: true}
+		if !md.LastSeen.IsZero() {
+			r.lastSeen.Time = md.LastSeen.UTC()
+		}
+		r.ip = nullString(md.IP)
+		r.userAgent = nullString(md.UserAgent)
+	}
+	return &r, nil
+}
+
+// setArgs returns arguments for SetStatement and SetNewStatement
+func (r *sqlRow) setArgs() []interface{} {
+	return []interface{}{r.id, r.value, r.now, r.expiresAt, r.userID, r.version, r.now, r.lastSeen, r.ip, r.userAgent}
+}
+
+// setVersionArgs returns arguments for SetVersionStatement
+func (r *sqlRow) setVersionArgs(version int64) []interface{} {
+	return []interface{}{r.id, r.value, r.expiresAt, r.userID, r.version, version, r.now, r.lastSeen, r.ip, r.userAgent}
+}
+
+// statementArgs returns leading args that query has placeholders for,
+// placeholders are ? (mysql, sqlite), ?N (sqlite) and $N (postgresql)
+func statementArgs(query string, args []interface{}) []interface{} {
+	var n, quote int
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if quote != 0 {
+			if int(c) == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '\'', '"':
+			quote = int(c)
+		case '?', '$':
+			j := i + 1
+			for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+				j++
+			}
+			if j == i+1 {
+				if c == '?' {
+					n++
+				}
+				continue
+			}
+			if p, err := strconv.Atoi(query[i+1 : j]); err == nil && p > n {
+				n = p
+			}
+			i = j - 1
+		}
+	}
+	if n == 0 || n >= len(args) {
+		return args
+	}
+	return args[:n]
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
 
 // Del deletes session data from sql db
@@ -107,19 +387,52 @@ func (s *SQL) Del(ctx context.Context, key string) error {
 	return err
 }
 
-// GC runs gc
-func (s *SQL) GC() error {
-	_, err := s.DB.Exec(s.GCStatement)
-	return err
+// UserSessions returns keys of sessions that associated with user id
+func (s *SQL) UserSessions(ctx context.Context, userID string) ([]string, error) {
+	rows, err := s.DB.QueryContext(ctx, s.UserSessionsStatement, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var r []string
+	for rows.Next() {
+		var k string
+		err = rows.Scan(&k)
+		if err != nil {
+			return nil, err
+		}
+		r = append(r, k)
+	}
+	return r, rows.Err()
 }
 
-func (s *SQL) gcWorker(d time.Duration) {
-	s.GC()
-	time.AfterFunc(d, func() { s.gcWorker(d) })
+// GC deletes expired sessions, returns number of deleted sessions
+func (s *SQL) GC() (int, error) {
+	r, err := s.DB.Exec(s.GCStatement)
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.RowsAffected()
+	return int(n), err
 }
 
-// GCEvery runs gc every given duration
+// GCEvery runs gc every given duration until Close
 func (s *SQL) GCEvery(d time.Duration) *SQL {
-	time.AfterFunc(d, func() { s.gcWorker(d) })
+	return s.GCEveryContext(context.Background(), d)
+}
+
+// GCEveryContext runs gc every given duration until ctx is done or Close
+func (s *SQL) GCEveryContext(ctx context.Context, d time.Duration) *SQL {
+	s.w.every(ctx, d, func() {
+		_, err := s.GC()
+		handleError(s.OnError, err)
+	})
 	return s
 }
+
+// Close stops gc and waits for running gc, DB is not closed
+func (s *SQL) Close() error {
+	s.w.close()
+	return nil
+}
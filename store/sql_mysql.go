@@ -0,0 +1,97 @@
+package store
+
+import (
+	"fmt"
+)
+
+// mysql stores times in UTC, compares with utc_timestamp
+// to not depend on server's time zone
+const (
+	mysqlInitSchema = `create table if not exists %[1]s (
+    id varchar(255) not null,
+    value mediumblob not null,
+    created_at datetime(6) not null,
+    expires_at datetime(6),
+    user_id varchar(255),
+    version bigint not null default 0,
+    updated_at datetime(6) not null,
+    last_seen_at datetime(6),
+    ip varchar(45),
+    user_agent text,
+    primary key (id),
+    index %[1]s_expires_at_idx (expires_at),
+    index %[1]s_user_id_idx (user_id)
+)`
+	mysqlSet = `insert into %s (id, value, created_at, expires_at, user_id, version, updated_at, last_seen_at, ip, user_agent)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+on duplicate key update
+    value = values(value),
+    expires_at = values(expires_at),
+    user_id = values(user_id),
+    version = values(version),
+    updated_at = values(updated_at),
+    last_seen_at = values(last_seen_at),
+    ip = values(ip),
+    user_agent = values(user_agent)`
+	// expires_at must be the last assignment, assignments after it see the new value
+	mysqlSetNew = `insert into %s (id, value, created_at, expires_at, user_id, version, updated_at, last_seen_at, ip, user_agent)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+on duplicate key update
+    value = if(expires_at <= utc_timestamp(6), values(value), value),
+    created_at = if(expires_at <= utc_timestamp(6), values(created_at), created_at),
+    user_id = if(expires_at <= utc_timestamp(6), values(user_id), user_id),
+    version = if(expires_at <= utc_timestamp(6), values(version), version),
+    updated_at = if(expires_at <= utc_timestamp(6), values(updated_at), updated_at),
+    last_seen_at = if(expires_at <= utc_timestamp(6), values(last_seen_at), last_seen_at),
+    ip = if(expires_at <= utc_timestamp(6), values(ip), ip),
+    user_agent = if(expires_at <= utc_timestamp(6), values(user_agent), user_agent),
+    expires_at = if(expires_at <= utc_timestamp(6), values(expires_at), expires_at)`
+	// mysql binds placeholders in order, arguments are selected as columns
+	// to keep the same argument order as other dialects
+	mysqlSetVersion = `update %s t
+join (select ? as id, ? as value, ? as expires_at, ? as user_id, ? as version, ? as expected_version,
+    ? as updated_at, ? as last_seen_at, ? as ip, ? as user_agent) a
+on t.id = a.id and t.version = a.expected_version
+set t.value = a.value,
+    t.expires_at = a.expires_at,
+    t.user_id = a.user_id,
+    t.version = a.version,
+    t.updated_at = a.updated_at,
+    t.last_seen_at = a.last_seen_at,
+    t.ip = a.ip,
+    t.user_agent = a.user_agent
+where t.expires_at is null or t.expires_at > utc_timestamp(6)`
+	mysqlGet          = `select value from %s where id = ? and (expires_at is null or expires_at > utc_timestamp(6))`
+	mysqlGetForUpdate = `select value from %s where id = ? and (expires_at is null or expires_at > utc_timestamp(6)) for update`
+	mysqlDel          = `delete from %s where id = ?`
+	mysqlGC           = `delete from %s where expires_at <= utc_timestamp(6)`
+	mysqlUserSessions = `select id from %s where user_id = ? and (expires_at is null or expires_at > utc_timestamp(6))`
+)
+
+// GenerateMySQLStatement generates mysql statement,
+// db must be opened with UTC location (default of "github.com/go-sql-driver/mysql").
+//
+// initSchema creates table only if not exists,
+// table that created without metadata columns must be migrated before use
+//
+//	alter table sessions
+//	    add column updated_at datetime(6) not null default '1970-01-01',
+//	    add column last_seen_at datetime(6),
+//	    add column ip varchar(45),
+//	    add column user_agent text;
+//	update sessions set updated_at = created_at;
+func (s *SQL) GenerateMySQLStatement(table string, initSchema bool) *SQL {
+	if initSchema {
+		s.initSchema("mysql", fmt.Sprintf(mysqlInitSchema, table))
+	}
+
+	s.SetStatement = fmt.Sprintf(mysqlSet, table)
+	s.GetStatement = fmt.Sprintf(mysqlGet, table)
+	s.DelStatement = fmt.Sprintf(mysqlDel, table)
+	s.GCStatement = fmt.Sprintf(mysqlGC, table)
+	s.UserSessionsStatement = fmt.Sprintf(mysqlUserSessions, table)
+	s.SetNewStatement = fmt.Sprintf(mysqlSetNew, table)
+	s.SetVersionStatement = fmt.Sprintf(mysqlSetVersion, table)
+	s.GetForUpdateStatement = fmt.Sprintf(mysqlGetForUpdate, table)
+	return s
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func openMySQL(t *testing.T) *sql.DB {
+	t.Helper()
+
+	host := os.Getenv("MYSQL_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+	port := os.Getenv("MYSQL_PORT")
+	if port == "" {
+		port = "3306"
+	}
+	user := os.Getenv("MYSQL_USER")
+	if user == "" {
+		user = "root"
+	}
+	password := os.Getenv("MYSQL_PASSWORD")
+	database := os.Getenv("MYSQL_DATABASE")
+	if database == "" {
+		database = "mysql"
+	}
+
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", user, password, host, port, database)
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatalf("can not open mysql database: %v", err)
+	}
+	return db
+}
+
+func TestSQL_MySQL(t *testing.T) {
+	t.Parallel()
+
+	db := openMySQL(t)
+	defer db.Close()
+
+	db.Exec(`drop table if exists __sql_mysql`)
+
+	s := (&SQL{DB: db}).
+		GenerateMySQLStatement("__sql_mysql", true)
+
+	testSQLDialect(t, s, "__sql_mysql")
+}
+
+func TestSQL_MySQLMigrate(t *testing.T) {
+	t.Parallel()
+
+	db := openMySQL(t)
+	defer db.Close()
+
+	db.Exec(`drop table if exists __sql_mysql_migrate`)
+
+	// table created before metadata columns
+	for _, q := range []string{
+		`create table __sql_mysql_migrate (id varchar(255) not null, value mediumblob not null, created_at datetime(6) not null, expires_at datetime(6), user_id varchar(255), version bigint not null default 0, primary key (id))`,
+		`insert into __sql_mysql_migrate (id, value, created_at) values ('old', '', '2020-01-01')`,
+		`alter table __sql_mysql_migrate
+    add column updated_at datetime(6) not null default '1970-01-01',
+    add column last_seen_at datetime(6),
+    add column ip varchar(45),
+    add column user_agent text`,
+		`update __sql_mysql_migrate set updated_at = created_at`,
+	} {
+		_, err := db.Exec(q)
+		assert.NoError(t, err)
+	}
+
+	s := (&SQL{DB: db}).
+		GenerateMySQLStatement("__sql_mysql_migrate", false)
+
+	testSQLDialect(t, s, "__sql_mysql_migrate")
+}
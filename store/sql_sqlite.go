@@ -0,0 +1,95 @@
+package store
+
+import (
+	"fmt"
+)
+
+// sqlite stores times as UTC text, compares with current time in the same format
+const (
+	sqliteInitSchema = `create table if not exists %[1]s (
+    id text not null,
+    value blob not null,
+    created_at datetime not null,
+    expires_at datetime,
+    user_id text,
+    version integer not null default 0,
+    updated_at datetime not null,
+    last_seen_at datetime,
+    ip text,
+    user_agent text,
+    primary key (id)
+)`
+	sqliteInitExpiresAtIndex = `create index if not exists %[1]s_expires_at_idx on %[1]s (expires_at)`
+	sqliteInitUserIDIndex    = `create index if not exists %[1]s_user_id_idx on %[1]s (user_id)`
+	sqliteNow                = `strftime('%%Y-%%m-%%d %%H:%%M:%%f', 'now')`
+	sqliteSet                = `insert into %s (id, value, created_at, expires_at, user_id, version, updated_at, last_seen_at, ip, user_agent)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+on conflict (id) do update
+set value = excluded.value,
+    expires_at = excluded.expires_at,
+    user_id = excluded.user_id,
+    version = excluded.version,
+    updated_at = excluded.updated_at,
+    last_seen_at = excluded.last_seen_at,
+    ip = excluded.ip,
+    user_agent = excluded.user_agent`
+	sqliteSetNew = `insert into %[1]s (id, value, created_at, expires_at, user_id, version, updated_at, last_seen_at, ip, user_agent)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+on conflict (id) do update
+set value = excluded.value,
+    created_at = excluded.created_at,
+    expires_at = excluded.expires_at,
+    user_id = excluded.user_id,
+    version = excluded.version,
+    updated_at = excluded.updated_at,
+    last_seen_at = excluded.last_seen_at,
+    ip = excluded.ip,
+    user_agent = excluded.user_agent
+where %[1]s.expires_at <= ` + sqliteNow
+	sqliteSetVersion = `update %s
+set value = ?2,
+    expires_at = ?3,
+    user_id = ?4,
+    version = ?5,
+    updated_at = ?7,
+    last_seen_at = ?8,
+    ip = ?9,
+    user_agent = ?10
+where id = ?1 and version = ?6 and (expires_at is null or expires_at > ` + sqliteNow + `)`
+	sqliteGet          = `select value from %s where id = ? and (expires_at is null or expires_at > ` + sqliteNow + `)`
+	sqliteDel          = `delete from %s where id = ?`
+	sqliteGC           = `delete from %s where expires_at <= ` + sqliteNow
+	sqliteUserSessions = `select id from %s where user_id = ? and (expires_at is null or expires_at > ` + sqliteNow + `)`
+	// sqlite does not have row lock, no-op update takes database write lock before read
+	sqliteGetForUpdate = `update %s set value = value where id = ? and (expires_at is null or expires_at > ` + sqliteNow + `) returning value`
+)
+
+// GenerateSQLiteStatement generates sqlite statement.
+//
+// initSchema creates table only if not exists,
+// table that created without metadata columns must be migrated before use
+//
+//	alter table sessions add column updated_at datetime not null default '1970-01-01 00:00:00.000';
+//	alter table sessions add column last_seen_at datetime;
+//	alter table sessions add column ip text;
+//	alter table sessions add column user_agent text;
+//	update sessions set updated_at = created_at;
+func (s *SQL) GenerateSQLiteStatement(table string, initSchema bool) *SQL {
+	if initSchema {
+		s.initSchema("sqlite",
+			fmt.Sprintf(sqliteInitSchema, table),
+			fmt.Sprintf(sqliteInitExpiresAtIndex, table),
+			fmt.Sprintf(sqliteInitUserIDIndex, table),
+		)
+	}
+
+	s.SetStatement = fmt.Sprintf(sqliteSet, table)
+	s.GetStatement = fmt.Sprintf(sqliteGet, table)
+	s.DelStatement = fmt.Sprintf(sqliteDel, table)
+	s.GCStatement = fmt.Sprintf(sqliteGC, table)
+	s.UserSessionsStatement = fmt.Sprintf(sqliteUserSessions, table)
+	s.SetNewStatement = fmt.Sprintf(sqliteSetNew, table)
+	s.SetVersionStatement = fmt.Sprintf(sqliteSetVersion, table)
+	s.GetForUpdateStatement = fmt.Sprintf(sqliteGetForUpdate, table)
+	return s
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/moonrhythm/session"
+)
+
+func TestSQL_SQLite(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "session.db")+"?_txlock=immediate")
+	if err != nil {
+		t.Fatalf("can not open sqlite database: %v", err)
+	}
+	defer db.Close()
+
+	s := (&SQL{DB: db}).
+		GenerateSQLiteStatement("__sql_sqlite", true)
+
+	testSQLDialect(t, s, "__sql_sqlite")
+}
+
+func TestSQLGCError(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "session.db"))
+	if err != nil {
+		t.Fatalf("can not open sqlite database: %v", err)
+	}
+
+	errs := make(chan error, 1)
+	s := (&SQL{DB: db, OnError: func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}}).
+		GenerateSQLiteStatement("__sql_sqlite_gc_error", true).
+		GCEvery(10 * time.Millisecond)
+	defer s.Close()
+
+	n, err := s.GC()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	db.Close()
+	select {
+	case err := <-errs:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Error("expected gc error reported")
+	}
+}
+
+func TestSQLInitSchemaError(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "session.db"))
+	if err != nil {
+		t.Fatalf("can not open sqlite database: %v", err)
+	}
+	db.Close()
+
+	var errs []error
+	(&SQL{DB: db, OnError: func(err error) { errs = append(errs, err) }}).
+		GenerateSQLiteStatement("__sql_sqlite_init_error", true)
+	if assert.Len(t, errs, 1) {
+		assert.ErrorContains(t, errs[0], "store/sql: init sqlite schema")
+	}
+}
+
+func TestSQL_SQLiteLegacyStatement(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "session.db"))
+	if err != nil {
+		t.Fatalf("can not open sqlite database: %v", err)
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`create table sessions (id text not null, value blob not null, created_at datetime not null, expires_at datetime, primary key (id))`)
+	assert.NoError(t, err)
+
+	s := &SQL{
+		DB: db,
+		SetStatement: `insert into sessions (id, value, created_at, expires_at) values (?, ?, ?, ?)
+on conflict (id) do update set value = excluded.value, expires_at = excluded.expires_at`,
+		GetStatement: `select value from sessions where id = ?`,
+	}
+
+	data := session.Data{"test": "123"}
+	err = s.Set(ctx, "a", data, session.StoreOption{TTL: time.Minute})
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+}
+
+func TestSQL_SQLiteMigrate(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("sqlite3", "file:"+filepath.Join(t.TempDir(), "session.db")+"?_txlock=immediate")
+	if err != nil {
+		t.Fatalf("can not open sqlite database: %v", err)
+	}
+	defer db.Close()
+
+	// table created before metadata columns
+	for _, q := range []string{
+		`create table sessions (id text not null, value blob not null, created_at datetime not null, expires_at datetime, user_id text, version integer not null default 0, primary key (id))`,
+		`insert into sessions (id, value, created_at) values ('old', x'', '2020-01-01 00:00:00.000')`,
+		`alter table sessions add column updated_at datetime not null default '1970-01-01 00:00:00.000'`,
+		`alter table sessions add column last_seen_at datetime`,
+		`alter table sessions add column ip text`,
+		`alter table sessions add column user_agent text`,
+		`update sessions set updated_at = created_at`,
+	} {
+		_, err = db.Exec(q)
+		assert.NoError(t, err)
+	}
+
+	s := (&SQL{DB: db}).
+		GenerateSQLiteStatement("sessions", false)
+
+	testSQLDialect(t, s, "sessions")
+}
@@ -52,6 +52,7 @@ func TestSQL_PostgreSQL(t *testing.T) {
 	s := (&SQL{DB: db}).
 		GeneratePostgreSQLStatement("__sql_postgresql", true).
 		GCEvery(50 * time.Millisecond)
+	defer s.Close()
 
 	opt := session.StoreOption{TTL: 20 * time.Millisecond}
 
@@ -94,6 +95,7 @@ func TestSQLWithoutTTL(t *testing.T) {
 	s := (&SQL{DB: db}).
 		GeneratePostgreSQLStatement("__sql_postgresql_without_ttl", true).
 		GCEvery(100 * time.Millisecond)
+	defer s.Close()
 
 	opt := session.StoreOption{}
 
@@ -107,3 +109,274 @@ func TestSQLWithoutTTL(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, data, b)
 }
+
+func TestSQLUserSessions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	db := openPostgreSQL(t)
+	defer db.Close()
+
+	db.Exec(`drop table if exists __sql_postgresql_user_sessions`)
+
+	s := (&SQL{DB: db}).
+		GeneratePostgreSQLStatement("__sql_postgresql_user_sessions", true)
+
+	data := make(session.Data)
+	data["test"] = "123"
+
+	s.Set(ctx, "a", data, session.StoreOption{UserID: "user1"})
+	s.Set(ctx, "b", data, session.StoreOption{UserID: "user1"})
+	s.Set(ctx, "c", data, session.StoreOption{UserID: "user2"})
+
+	keys, err := s.UserSessions(ctx, "user1")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, keys)
+
+	s.Set(ctx, "b", data, session.StoreOption{})
+	keys, err = s.UserSessions(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, keys)
+}
+
+func TestSQLSetIfVersion(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	db := openPostgreSQL(t)
+	defer db.Close()
+
+	db.Exec(`drop table if exists __sql_postgresql_version`)
+
+	s := (&SQL{DB: db}).
+		GeneratePostgreSQLStatement("__sql_postgresql_version", true)
+
+	opt := session.StoreOption{TTL: time.Minute}
+	data := session.Data{"test": "123", "_session/version": int64(1)}
+
+	err := s.SetIfVersion(ctx, "a", data, 1, opt)
+	assert.Equal(t, session.ErrConflict, err, "expected conflict when session not exists")
+
+	err = s.SetIfVersion(ctx, "a", data, 0, opt)
+	assert.NoError(t, err)
+
+	err = s.SetIfVersion(ctx, "a", data, 0, opt)
+	assert.Equal(t, session.ErrConflict, err)
+
+	data = session.Data{"test": "456", "_session/version": int64(2)}
+	err = s.SetIfVersion(ctx, "a", data, 1, opt)
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+}
+
+func TestSQLPatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	db := openPostgreSQL(t)
+	defer db.Close()
+
+	db.Exec(`drop table if exists __sql_postgresql_patch`)
+
+	s := (&SQL{DB: db}).
+		GeneratePostgreSQLStatement("__sql_postgresql_patch", true)
+
+	opt := session.StoreOption{TTL: time.Minute}
+
+	err := s.Patch(ctx, "a", session.Data{"a": 1}, nil, opt)
+	assert.Equal(t, session.ErrNotFound, err)
+
+	s.Set(ctx, "a", session.Data{"a": 1, "b": 2, "c": 3}, opt)
+
+	err = s.Patch(ctx, "a", session.Data{"a": 10}, nil, opt)
+	assert.NoError(t, err)
+	err = s.Patch(ctx, "a", session.Data{"d": 4}, []string{"b"}, opt)
+	assert.NoError(t, err)
+
+	b, err := s.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, session.Data{"a": 10, "c": 3, "d": 4}, b)
+}
+
+func TestSQL_PostgreSQLStatements(t *testing.T) {
+	t.Parallel()
+
+	db := openPostgreSQL(t)
+	defer db.Close()
+
+	db.Exec(`drop table if exists __sql_postgresql_statements`)
+
+	s := (&SQL{DB: db}).
+		GeneratePostgreSQLStatement("__sql_postgresql_statements", true)
+
+	testSQLDialect(t, s, "__sql_postgresql_statements")
+}
+
+func TestSQLStatementArgs(t *testing.T) {
+	t.Parallel()
+
+	args := []interface{}{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+
+	assert.Len(t, statementArgs(pgsqlSet, args), 10)
+	assert.Len(t, statementArgs(mysqlSetVersion, args), 10)
+	assert.Len(t, statementArgs(sqliteSetVersion, args), 10)
+	assert.Len(t, statementArgs(`insert into s (id, value, created_at, expires_at) values ($1, $2, $3, $4)`, args), 4)
+	assert.Len(t, statementArgs(`insert into s (id, value, created_at, expires_at) values (?, ?, ?, ?)`, args), 4)
+	assert.Len(t, statementArgs(`update s set value = $2, note = '$9?' where id = $1`, args), 2, "expected placeholders in string ignored")
+	assert.Len(t, statementArgs(`delete from s`, args), 10, "expected all arguments when placeholders not recognized")
+}
+
+// testSQLDialect tests store operations with generated statements
+func testSQLDialect(t *testing.T, s *SQL, table string) {
+	ctx := context.Background()
+
+	t.Run("GetSet", func(t *testing.T) {
+		data := session.Data{"test": "123"}
+
+		err := s.Set(ctx, "a", data, session.StoreOption{TTL: 20 * time.Millisecond})
+		assert.NoError(t, err)
+
+		time.Sleep(100 * time.Millisecond)
+		b, err := s.Get(ctx, "a")
+		assert.Nil(t, b)
+		assert.Equal(t, session.ErrNotFound, err, "expected expired key return not found")
+
+		n, err := s.GC()
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, n, 1)
+
+		err = s.Set(ctx, "a", data, session.StoreOption{TTL: time.Minute})
+		assert.NoError(t, err)
+		b, err = s.Get(ctx, "a")
+		assert.NoError(t, err)
+		assert.Equal(t, data, b)
+
+		err = s.Set(ctx, "a", session.Data{"test": "456"}, session.StoreOption{})
+		assert.NoError(t, err)
+		b, err = s.Get(ctx, "a")
+		assert.NoError(t, err)
+		assert.Equal(t, session.Data{"test": "456"}, b)
+
+		s.Del(ctx, "a")
+		_, err = s.Get(ctx, "a")
+		assert.Equal(t, session.ErrNotFound, err)
+	})
+
+	t.Run("UserSessions", func(t *testing.T) {
+		data := session.Data{"test": "123"}
+
+		s.Set(ctx, "user_a", data, session.StoreOption{UserID: "user1"})
+		s.Set(ctx, "user_b", data, session.StoreOption{UserID: "user1"})
+		s.Set(ctx, "user_c", data, session.StoreOption{UserID: "user2"})
+
+		keys, err := s.UserSessions(ctx, "user1")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"user_a", "user_b"}, keys)
+
+		s.Set(ctx, "user_b", data, session.StoreOption{})
+		keys, err = s.UserSessions(ctx, "user1")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"user_a"}, keys)
+	})
+
+	t.Run("SetIfVersion", func(t *testing.T) {
+		opt := session.StoreOption{TTL: time.Minute}
+		data := session.Data{"test": "123", "_session/version": int64(1)}
+
+		err := s.SetIfVersion(ctx, "version", data, 1, opt)
+		assert.Equal(t, session.ErrConflict, err, "expected conflict when session not exists")
+
+		err = s.SetIfVersion(ctx, "version", data, 0, opt)
+		assert.NoError(t, err)
+
+		err = s.SetIfVersion(ctx, "version", data, 0, opt)
+		assert.Equal(t, session.ErrConflict, err)
+
+		data = session.Data{"test": "456", "_session/version": int64(2)}
+		err = s.SetIfVersion(ctx, "version", data, 1, opt)
+		assert.NoError(t, err)
+
+		b, err := s.Get(ctx, "version")
+		assert.NoError(t, err)
+		assert.Equal(t, data, b)
+
+		s.Set(ctx, "version_expired", data, session.StoreOption{TTL: 20 * time.Millisecond})
+		time.Sleep(100 * time.Millisecond)
+		err = s.SetIfVersion(ctx, "version_expired", data, 0, opt)
+		assert.NoError(t, err, "expected expired session treat as not exists")
+
+		// session saved before enable optimistic lock has version 0
+		s.Set(ctx, "version_legacy", session.Data{"test": "123"}, opt)
+		data = session.Data{"test": "456", "_session/version": int64(1)}
+		err = s.SetIfVersion(ctx, "version_legacy", data, 0, opt)
+		assert.NoError(t, err, "expected legacy session matches version 0")
+		b, err = s.Get(ctx, "version_legacy")
+		assert.NoError(t, err)
+		assert.Equal(t, data, b)
+
+		err = s.SetIfVersion(ctx, "version_legacy", data, 0, opt)
+		assert.Equal(t, session.ErrConflict, err)
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		opt := session.StoreOption{TTL: time.Minute}
+
+		err := s.Patch(ctx, "patch", session.Data{"a": 1}, nil, opt)
+		assert.Equal(t, session.ErrNotFound, err)
+
+		s.Set(ctx, "patch", session.Data{"a": 1, "b": 2, "c": 3}, opt)
+
+		err = s.Patch(ctx, "patch", session.Data{"a": 10}, nil, opt)
+		assert.NoError(t, err)
+		err = s.Patch(ctx, "patch", session.Data{"d": 4}, []string{"b"}, opt)
+		assert.NoError(t, err)
+
+		b, err := s.Get(ctx, "patch")
+		assert.NoError(t, err)
+		assert.Equal(t, session.Data{"a": 10, "c": 3, "d": 4}, b)
+	})
+	t.Run("Metadata", func(t *testing.T) {
+		defer func() { s.Metadata = nil }()
+		s.Metadata = func(data session.Data) SQLMetadata {
+			ip, _ := data["ip"].(string)
+			ua, _ := data["ua"].(string)
+			return SQLMetadata{UserID: "user3", IP: ip, UserAgent: ua}
+		}
+
+		opt := session.StoreOption{TTL: time.Minute}
+		data := session.Data{"ip": "127.0.0.1", "ua": "Go-http-client/1.1"}
+
+		err := s.Set(ctx, "metadata", data, opt)
+		assert.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+		err = s.Set(ctx, "metadata", data, opt)
+		assert.NoError(t, err)
+
+		var (
+			ip, ua, userID string
+			updated, seen  bool
+		)
+		err = s.DB.QueryRow(fmt.Sprintf(
+			`select ip, user_agent, user_id, created_at < updated_at, last_seen_at is not null from %s where id = 'metadata'`,
+			table,
+		)).Scan(&ip, &ua, &userID, &updated, &seen)
+		assert.NoError(t, err)
+		assert.Equal(t, "127.0.0.1", ip)
+		assert.Equal(t, "Go-http-client/1.1", ua)
+		assert.Equal(t, "user3", userID)
+		assert.True(t, updated, "expected created at not change on upsert")
+		assert.True(t, seen)
+
+		err = s.Set(ctx, "metadata", data, session.StoreOption{UserID: "user4"})
+		assert.NoError(t, err)
+		err = s.DB.QueryRow(fmt.Sprintf(`select user_id from %s where id = 'metadata'`, table)).Scan(&userID)
+		assert.NoError(t, err)
+		assert.Equal(t, "user4", userID, "expected associated user id take precedence")
+	})
+}
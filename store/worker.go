@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// worker runs background tasks of a store until closed
+type worker struct {
+	once   sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func (w *worker) init() {
+	w.once.Do(func() {
+		w.ctx, w.cancel = context.WithCancel(context.Background())
+	})
+}
+
+// every runs f every given duration until ctx is done or worker is closed,
+// panics in caller's goroutine if d is not positive
+func (w *worker) every(ctx context.Context, d time.Duration, f func()) {
+	if d <= 0 {
+		panic("store: non-positive interval " + d.String())
+	}
+	w.init()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		t := time.NewTicker(d)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.ctx.Done():
+				return
+			case <-t.C:
+				f()
+			}
+		}
+	}()
+}
+
+// close stops all tasks and waits for running tasks
+func (w *worker) close() {
+	w.init()
+	w.cancel()
+	w.wg.Wait()
+}
+
+// handleError calls onError if set
+func handleError(onError func(error), err error) {
+	if err != nil && onError != nil {
+		onError(err)
+	}
+}
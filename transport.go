@@ -0,0 +1,85 @@
+package session
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Transport carries session id between client and server
+type Transport interface {
+	// Get gets session id from request,
+	// returns empty string if request does not have session id
+	Get(r *http.Request, name string) string
+
+	// Set sets session id to response
+	Set(w http.ResponseWriter, s *Session, value string)
+}
+
+// CookieTransport carries session id in cookie
+type CookieTransport struct{}
+
+// Get gets session id from cookie
+func (CookieTransport) Get(r *http.Request, name string) string {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// Set sets session id to cookie
+func (CookieTransport) Set(w http.ResponseWriter, s *Session, value string) {
+	http.SetCookie(w, makeCookie(s, s.Name, value))
+}
+
+// HeaderTransport carries session id in request and response header
+type HeaderTransport struct {
+	// Header is the request header name,
+	// if Header is empty, session name will be used
+	Header string
+
+	// Scheme is the authorization scheme, ex. Bearer
+	Scheme string
+
+	// ResponseHeader is the response header name to send new session id,
+	// if ResponseHeader is empty, Header will be used
+	ResponseHeader string
+}
+
+// BearerTransport carries session id in Authorization header using Bearer scheme
+var BearerTransport Transport = &HeaderTransport{
+	Header: "Authorization",
+	Scheme: "Bearer",
+}
+
+func (t *HeaderTransport) header(name string) string {
+	if t.Header == "" {
+		return name
+	}
+	return t.Header
+}
+
+// Get gets session id from request header
+func (t *HeaderTransport) Get(r *http.Request, name string) string {
+	v := r.Header.Get(t.header(name))
+	if t.Scheme == "" {
+		return v
+	}
+
+	if len(v) <= len(t.Scheme) || !strings.EqualFold(v[:len(t.Scheme)], t.Scheme) || v[len(t.Scheme)] != ' ' {
+		return ""
+	}
+	return strings.TrimSpace(v[len(t.Scheme)+1:])
+}
+
+// Set sets session id to response header
+func (t *HeaderTransport) Set(w http.ResponseWriter, s *Session, value string) {
+	h := t.ResponseHeader
+	if h == "" {
+		h = t.header(s.Name)
+	}
+	if t.Scheme != "" {
+		value = t.Scheme + " " + value
+	}
+	w.Header().Set(h, value)
+}